@@ -0,0 +1,324 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"fmt"
+	"math"
+)
+
+// RowError is error of evaluation of one row in batch
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+// Unwrap returns error of row
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError holds errors of all failed rows in batch
+type BatchError []*RowError
+
+func (e BatchError) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more errors)", e[0], len(e)-1)
+}
+
+// vector is column of values or single value broadcasted to all rows
+type vector struct {
+	values []float64
+	scalar float64
+}
+
+func (v vector) at(i int) float64 {
+	if v.values == nil {
+		return v.scalar
+	}
+	return v.values[i]
+}
+
+// batch is state of columnar evaluation
+type batch struct {
+	p       *program
+	columns [][]float64
+	alive   []bool
+	errs    BatchError
+}
+
+// ExecuteBatch executes prepared expression for every row of columns and writes results to out.
+// All columns must have length of out. Failed rows are reported by BatchError and get NaN.
+func (c *Calc) ExecuteBatch(columns map[string][]float64, out []float64) error {
+	return c.ExecuteBatchMasked(columns, nil, out, nil)
+}
+
+// ExecuteBatchMasked is like ExecuteBatch, but rows marked false in any mask of used columns
+// are skipped. Result validity of each row is written to valid if it is not nil.
+func (c *Calc) ExecuteBatchMasked(columns map[string][]float64, masks map[string][]bool, out []float64, valid []bool) error {
+	if c.program == nil {
+		return &NotPreparedError{}
+	}
+	if valid != nil && len(valid) != len(out) {
+		return fmt.Errorf("expected %d rows of validity, got %d", len(out), len(valid))
+	}
+	b := &batch{p: c.program, columns: make([][]float64, len(c.program.names)), alive: make([]bool, len(out))}
+	for i := range b.alive {
+		b.alive[i] = true
+	}
+	for slot, name := range c.program.names {
+		col, ok := columns[name]
+		if !ok {
+			return &UnknownVariableError{Name: name}
+		}
+		if len(col) != len(out) {
+			return fmt.Errorf("column '%s' has %d rows, expected %d", name, len(col), len(out))
+		}
+		b.columns[slot] = col
+		mask, ok := masks[name]
+		if !ok {
+			continue
+		}
+		if len(mask) != len(out) {
+			return fmt.Errorf("mask of column '%s' has %d rows, expected %d", name, len(mask), len(out))
+		}
+		for i, ok := range mask {
+			b.alive[i] = b.alive[i] && ok
+		}
+	}
+	res, err := b.eval(b.p.root, b.alive)
+	if err != nil {
+		return err
+	}
+	for i := range out {
+		if b.alive[i] {
+			out[i] = res.at(i)
+		} else {
+			out[i] = math.NaN()
+		}
+		if valid != nil {
+			valid[i] = b.alive[i]
+		}
+	}
+	if len(b.errs) > 0 {
+		return b.errs
+	}
+	return nil
+}
+
+func (b *batch) fail(row int, err error) {
+	b.alive[row] = false
+	b.errs = append(b.errs, &RowError{Row: row, Err: err})
+}
+
+// eval evaluates node for rows marked in mask
+func (b *batch) eval(n *node, mask []bool) (vector, error) {
+	switch n.Type {
+	case literalNode:
+		return vector{scalar: n.FValue}, nil
+	case variableNode:
+		if n.Slot < 0 {
+			break
+		}
+		return vector{values: b.columns[n.Slot]}, nil
+	case negNode:
+		arg, err := b.eval(n.Args[0], mask)
+		if err != nil {
+			return vector{}, err
+		}
+		if arg.values == nil {
+			return vector{scalar: -arg.scalar}, nil
+		}
+		res := make([]float64, len(mask))
+		for i, v := range arg.values {
+			res[i] = -v
+		}
+		return vector{values: res}, nil
+	case operatorNode:
+		op, ok := b.p.operators[n.SValue]
+		if !ok {
+			return vector{}, &UnknownOperatorError{Op: n.SValue}
+		}
+		if op.ValueFn != nil {
+			break
+		}
+		left, err := b.eval(n.Args[0], mask)
+		if err != nil {
+			return vector{}, err
+		}
+		right, err := b.eval(n.Args[1], mask)
+		if err != nil {
+			return vector{}, err
+		}
+		if left.values == nil && right.values == nil {
+			return b.apply(mask, []vector{left, right}, true, func(args []float64) (float64, error) {
+				res, err := op.Fn(args[0], args[1])
+				if err != nil {
+					return 0, newEvalError(n, fmt.Errorf("operator '%s': %w", op.Op, err))
+				}
+				return res, nil
+			}), nil
+		}
+		return b.binary(mask, left, right, n, op), nil
+	case functionNode:
+		fn, ok := lookupFunction(b.p.functions, n.SValue, len(n.Args))
+		if !ok {
+			return vector{}, &UnknownFunctionError{Name: n.SValue}
+		}
+		if fn.ValueFn != nil {
+			break
+		}
+		if len(n.Args) != fn.Places {
+			return vector{}, &ArityError{Func: fn.Name, Want: fn.Places, Got: len(n.Args)}
+		}
+		args := make([]vector, len(n.Args))
+		for i, arg := range n.Args {
+			var err error
+			if args[i], err = b.eval(arg, mask); err != nil {
+				return vector{}, err
+			}
+		}
+		call := fn.Fn
+		if fn.body != nil {
+			call = b.define(fn)
+		}
+		return b.apply(mask, args, fn.foldable(), func(args []float64) (float64, error) {
+			res, err := call(args...)
+			if err != nil {
+				return 0, newEvalError(n, &FunctionError{Func: fn.Name, Pos: n.Pos, Err: err})
+			}
+			return res, nil
+		}), nil
+	case conditionNode:
+		cond, err := b.eval(n.Args[0], mask)
+		if err != nil {
+			return vector{}, err
+		}
+		masks := [2][]bool{make([]bool, len(mask)), make([]bool, len(mask))}
+		for i, ok := range mask {
+			if ok && b.alive[i] {
+				if cond.at(i) != 0 {
+					masks[0][i] = true
+				} else {
+					masks[1][i] = true
+				}
+			}
+		}
+		a, err := b.eval(n.Args[1], masks[0])
+		if err != nil {
+			return vector{}, err
+		}
+		c, err := b.eval(n.Args[2], masks[1])
+		if err != nil {
+			return vector{}, err
+		}
+		res := make([]float64, len(mask))
+		for i := range res {
+			switch {
+			case masks[0][i]:
+				res[i] = a.at(i)
+			case masks[1][i]:
+				res[i] = c.at(i)
+			}
+		}
+		return vector{values: res}, nil
+	}
+	return vector{}, fmt.Errorf("%s is not supported in batch mode", n.format(b.p.operators))
+}
+
+// define returns function evaluating body of defined function with operators and functions of program,
+// so numeric policy of program applies inside of it
+func (b *batch) define(fn *Function) func(args ...float64) (float64, error) {
+	return func(args ...float64) (float64, error) {
+		values := make([]Value, len(args))
+		for i, arg := range args {
+			values[i] = Number(arg)
+		}
+		res, err := b.p.call(fn, values, &state{})
+		if err != nil {
+			return 0, err
+		}
+		return asNumber(res)
+	}
+}
+
+// binary applies operator to every alive row in mask
+func (b *batch) binary(mask []bool, left, right vector, n *node, op *Operator) vector {
+	res := make([]float64, len(mask))
+	fn := op.Fn
+	for i, ok := range mask {
+		if !ok || !b.alive[i] {
+			continue
+		}
+		v, err := fn(left.at(i), right.at(i))
+		if err != nil {
+			b.fail(i, newEvalError(n, fmt.Errorf("operator '%s': %w", op.Op, err)))
+			continue
+		}
+		res[i] = v
+	}
+	return vector{values: res}
+}
+
+// apply calls fn for every alive row in mask, scalar arguments of pure fn give scalar result
+func (b *batch) apply(mask []bool, args []vector, pure bool, fn func(args []float64) (float64, error)) vector {
+	buf := make([]float64, len(args))
+	scalar := pure
+	for _, arg := range args {
+		scalar = scalar && arg.values == nil
+	}
+	if scalar {
+		for i, arg := range args {
+			buf[i] = arg.scalar
+		}
+		res, err := fn(buf)
+		if err != nil {
+			for i, ok := range mask {
+				if ok && b.alive[i] {
+					b.fail(i, err)
+				}
+			}
+		}
+		return vector{scalar: res}
+	}
+	res := make([]float64, len(mask))
+	for i, ok := range mask {
+		if !ok || !b.alive[i] {
+			continue
+		}
+		for j, arg := range args {
+			buf[j] = arg.at(i)
+		}
+		v, err := fn(buf)
+		if err != nil {
+			b.fail(i, err)
+			continue
+		}
+		res[i] = v
+	}
+	return vector{values: res}
+}
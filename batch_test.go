@@ -0,0 +1,124 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestExecuteBatch(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddOperators(LogicOperators)
+	errNegative := errors.New("negative argument")
+	c.AddFunction(NewFunction("sqrt", func(args ...float64) (float64, error) {
+		if args[0] < 0 {
+			return 0, errNegative
+		}
+		return math.Sqrt(args[0]), nil
+	}, 1))
+	if err := c.Prepare("x > 0 ? sqrt(x) * k : sqrt(-x) + 100"); err != nil {
+		t.Fatal(err)
+	}
+	columns := map[string][]float64{
+		"x": {4, -9, 16, 0},
+		"k": {1, 1, 2, 3},
+	}
+	out := make([]float64, 4)
+	if err := c.ExecuteBatch(columns, out); err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{2, 103, 8, 100}
+	for i := range expected {
+		if out[i] != expected[i] {
+			t.Errorf("Row %d: expected %f, actual %f", i, expected[i], out[i])
+		}
+	}
+
+	if err := c.Prepare("sqrt(x) + k * 2"); err != nil {
+		t.Fatal(err)
+	}
+	valid := make([]bool, 4)
+	err := c.ExecuteBatchMasked(columns, map[string][]bool{"k": {true, true, true, false}}, out, valid)
+	var batchErr BatchError
+	if !errors.As(err, &batchErr) || len(batchErr) != 1 || batchErr[0].Row != 1 || !errors.Is(batchErr[0], errNegative) {
+		t.Fatalf("Expected error of row 1, got %v", err)
+	}
+	expectedValid := []bool{true, false, true, false}
+	for i := range expectedValid {
+		if valid[i] != expectedValid[i] {
+			t.Errorf("Row %d: expected valid %v, actual %v", i, expectedValid[i], valid[i])
+		}
+	}
+	if out[0] != 4 || out[2] != 8 || !math.IsNaN(out[1]) || !math.IsNaN(out[3]) {
+		t.Errorf("Unexpected results %v", out)
+	}
+
+	if err := c.ExecuteBatch(map[string][]float64{"x": {1}}, out); err == nil {
+		t.Error("Expected error for missing column")
+	}
+	if err := c.ExecuteBatch(map[string][]float64{"x": {1}, "k": {1}}, out); err == nil {
+		t.Error("Expected error for short columns")
+	}
+
+	calls := 0
+	c.AddFunction(NewFunction("rnd", func(args ...float64) (float64, error) {
+		calls++
+		return float64(calls), nil
+	}, 0))
+	if err := c.Prepare("x + rnd()"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ExecuteBatch(map[string][]float64{"x": {0, 0, 0, 0}}, out); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 4 || out[0] == out[3] {
+		t.Errorf("Expected nondeterministic function called for every row, got %d calls and %v", calls, out)
+	}
+
+	c.SetNumericPolicy(DivisionByZeroPolicy, 0)
+	if err := c.Define("inv1(a) = 1 / a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Prepare("inv1(x)"); err != nil {
+		t.Fatal(err)
+	}
+	err = c.ExecuteBatch(map[string][]float64{"x": {2, 0, 4, 1}}, out)
+	if !errors.As(err, &batchErr) || len(batchErr) != 1 || batchErr[0].Row != 1 || !errors.Is(batchErr[0], ErrDivisionByZero) {
+		t.Errorf("Expected %v in row 1, got %v", ErrDivisionByZero, err)
+	}
+}
+
+func BenchmarkExecuteBatch(b *testing.B) {
+	c, _, _ := benchmarkCalc(b)
+	const rows = 1000
+	columns := map[string][]float64{"price": make([]float64, rows), "qty": make([]float64, rows), "discount": make([]float64, rows)}
+	for i := 0; i < rows; i++ {
+		columns["price"][i], columns["qty"][i], columns["discount"][i] = float64(i), 10, 0.1
+	}
+	out := make([]float64, rows)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.ExecuteBatch(columns, out)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+// Bind returns copy of calculator with prepared expression where free variables of vars are
+// replaced by their values and constant sub-expressions are computed. Residual expression
+// depends only on remaining variables. Variables absent in expression are ignored.
+func (c *Calc) Bind(vars map[string]float64) (*Calc, error) {
+	if c.program == nil {
+		return nil, &NotPreparedError{}
+	}
+	res := *c
+	res.functions = make(map[string]*Function, len(c.functions))
+	for name, fn := range c.functions {
+		res.functions[name] = fn
+	}
+	res.operators = make(map[string]*Operator, len(c.operators))
+	for name, op := range c.operators {
+		res.operators[name] = op
+	}
+	res.program = newProgram(bind(c.program.root, vars), &res)
+	return &res, nil
+}
+
+// Expression returns source of prepared expression after optimization or empty string if nothing is prepared
+func (c *Calc) Expression() string {
+	if c.program == nil {
+		return ""
+	}
+	return c.program.root.format(c.program.operators)
+}
+
+// bind returns copy of tree with free variables of vars replaced by literals
+func bind(n *node, vars map[string]float64) *node {
+	if n.Type == variableNode && n.Slot >= 0 {
+		if v, ok := vars[n.SValue]; ok {
+			return folded(n, v)
+		}
+	}
+	res := *n
+	res.Cache = 0
+	res.Args = make([]*node, len(n.Args))
+	for i, arg := range n.Args {
+		res.Args[i] = bind(arg, vars)
+	}
+	return &res
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBind(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddOperators(LogicOperators)
+	c.AddFunctions(MathFunctions)
+	c.AddFunctions(ListFunctions)
+	tests := []struct {
+		expression string
+		vars       map[string]float64
+		want       string
+		layout     map[string]int
+	}{
+		{"x * (rate + 1) * sqrt(base)", map[string]float64{"rate": 0.5, "base": 16}, "x * 1.5 * 4", map[string]int{"x": 0}},
+		{"a > 1 ? x * a : y", map[string]float64{"a": 2}, "x * 2", map[string]int{"x": 0}},
+		{"sum(map(xs, x => x * k + y))", map[string]float64{"x": 5, "k": 2}, "sum(map(xs, x => x * 2 + y))", map[string]int{"xs": 0, "y": 1}},
+		{"t = p * r; t + q", map[string]float64{"r": 0.2, "t": 7}, "t = p * 0.2; t + q", map[string]int{"p": 0, "q": 1}},
+		{"a * b", map[string]float64{"a": 2, "b": 3}, "6", map[string]int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			if err := c.Prepare(tt.expression); err != nil {
+				t.Fatal(err)
+			}
+			bound, err := c.Bind(tt.vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := bound.Expression(); got != tt.want {
+				t.Errorf("Expression() = %q, want %q", got, tt.want)
+			}
+			if got := bound.Layout(); !reflect.DeepEqual(got, tt.layout) {
+				t.Errorf("Layout() = %v, want %v", got, tt.layout)
+			}
+			if c.Expression() == bound.Expression() {
+				t.Error("expected prepared expression of calculator to be unchanged")
+			}
+		})
+	}
+}
+
+func TestBindExecute(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.SetFastMath(true)
+	if _, err := c.Bind(nil); !errors.As(err, new(*NotPreparedError)) {
+		t.Errorf("expected NotPreparedError, got %v", err)
+	}
+	if err := c.Prepare("price * qty * (1 - discount) + shipping * 2"); err != nil {
+		t.Fatal(err)
+	}
+	bound, err := c.Bind(map[string]float64{"discount": 0.25, "shipping": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bound.Expression(); got != "price * qty * 0.75 + 10" {
+		t.Errorf("Expression() = %q", got)
+	}
+	vars := map[string]float64{"price": 10, "qty": 4, "discount": 0.25, "shipping": 5}
+	want, err := c.Execute(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := bound.Execute(map[string]float64{"price": 10, "qty": 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Execute() = %v, want %v", got, want)
+	}
+	if err := bound.Prepare("x + 1"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Expression() == bound.Expression() {
+		t.Error("expected calculators to be independent")
+	}
+}
+
+func TestExpressionRoundTrip(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddFunctions(MathFunctions)
+	vars := map[string]float64{"x": 2, "y": 3}
+	for _, expression := range []string{"-(2^x)", "-(2^x) + y", "-(2 * x) ^ 2", "-(x - 1) * y", "-sqrt(x * y) - -x", "(-x) ^ 2", "-x * y"} {
+		if err := c.Prepare(expression); err != nil {
+			t.Fatal(err)
+		}
+		want, err := c.Execute(vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bound, err := c.Bind(map[string]float64{"y": vars["y"]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, printed := range []string{c.Expression(), bound.Expression()} {
+			if err := c.Prepare(printed); err != nil {
+				t.Fatalf("%s: %v", printed, err)
+			}
+			got, err := c.Execute(vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("%s printed as %s: expected %f, got %f", expression, printed, want, got)
+			}
+			if err := c.Prepare(expression); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
@@ -21,107 +21,294 @@
 package executor
 
 import (
-	"errors"
+	"context"
 	"fmt"
 )
 
+// DefaultRecursionLimit is default maximum nesting of defined function calls
+const DefaultRecursionLimit = 100
+
 // Calc calculates expressions
 type Calc struct {
-	preparedTokens []*token
+	program        *program
 	functions      map[string]*Function
 	operators      map[string]*Operator
+	recursionLimit int
+	stepLimit      int
+	callLimit      int
+	limits         Limits
+	numeric        numeric
+	fastMath       bool
 }
 
 // NewCalc instantinates new calculator
 func NewCalc() *Calc {
 	c := &Calc{
-		functions: map[string]*Function{},
-		operators: map[string]*Operator{},
+		functions:      map[string]*Function{},
+		operators:      map[string]*Operator{},
+		recursionLimit: DefaultRecursionLimit,
 	}
 	return c
 }
 
-// Prepare expression before execution
+// Prepare expression before execution.
+// Expression may be a script of statements separated by `;`:
+// `name = expr` assigns variable, `let name = expr` binds local temporary,
+// value of last statement is result of execution.
+// Constant sub-expressions are computed and identities like `x*1 = x` are applied here.
+// Numeric expressions are compiled, so operators and functions are resolved here.
 func (c *Calc) Prepare(expression string) error {
-	t := newTokenizer(expression, c.operators)
+	return c.PrepareLimits(expression, c.limits)
+}
+
+// PrepareLimits prepares expression like Prepare, but with limits instead of ones set by SetLimits
+func (c *Calc) PrepareLimits(expression string, limits Limits) error {
+	t := c.newTokenizer(expression, limits)
 	if err := t.tokenize(); err != nil {
 		return err
 	}
-	tkns, err := t.toRPN()
+	root, err := c.newParser(expression, t.tkns, limits).parseScript()
 	if err != nil {
 		return err
 	}
-	c.preparedTokens = tkns
+	if err := limits.check(root, c.functions); err != nil {
+		return err
+	}
+	c.program = newProgram(root, c)
 	return nil
 }
 
-// Execute prepared expression with variables at `vars` argument
+func (c *Calc) newTokenizer(expression string, limits Limits) *tokenizer {
+	t := newTokenizer(expression, c.operators)
+	t.limits = limits
+	return t
+}
+
+func (c *Calc) newParser(src string, tkns []*token, limits Limits) *parser {
+	p := newParser(tkns, c.operators)
+	p.src = src
+	if limits.MaxDepth > 0 {
+		p.maxDepth = limits.MaxDepth
+	}
+	return p
+}
+
+// Layout returns slot indexes of variables of prepared expression for ExecuteSlots
+func (c *Calc) Layout() map[string]int {
+	if c.program == nil {
+		return nil
+	}
+	layout := make(map[string]int, len(c.program.layout))
+	for name, slot := range c.program.layout {
+		layout[name] = slot
+	}
+	return layout
+}
+
+// Stats returns statistics of prepared expression
+func (c *Calc) Stats() Stats {
+	if c.program == nil {
+		return Stats{}
+	}
+	return c.program.stats
+}
+
+// Execute prepared expression with variables at `vars` argument.
+// All variables of expression must be present in `vars`.
 func (c *Calc) Execute(vars map[string]float64) (float64, error) {
-	if len(c.preparedTokens) == 0 {
-		return 0, errors.New("must prepare expression")
-	}
-	if vars == nil {
-		vars = map[string]float64{}
-	}
-	var stack []float64
-	for _, tkn := range c.preparedTokens {
-		switch tkn.Type {
-		case literalType:
-			stack = append(stack, tkn.FValue)
-		case operatorType:
-			sz := len(stack)
-			if sz < 2 {
-				return 0, errors.New("empty stack")
-			}
-			var args []float64
-			args, stack = stack[sz-2:], stack[:sz-2]
-
-			if op, ok := c.operators[tkn.SValue]; ok {
-				res, err := op.Fn(args[0], args[1])
-				if err != nil {
-					return 0, err
-				}
-				stack = append(stack, res)
-			} else {
-				return 0, fmt.Errorf("unknown operator '%s'", tkn.SValue)
-			}
-		case functionType:
-			fn, exists := c.functions[tkn.SValue]
-			if !exists {
-				return 0, fmt.Errorf("unknown function '%s'", tkn.SValue)
-			}
-			sz := len(stack)
-			if sz < fn.Places {
-				return 0, errors.New("not enough args")
-			}
-			var args []float64
-			args, stack = stack[sz-fn.Places:], stack[:sz-fn.Places]
-			res, err := fn.Fn(args...)
-			if err != nil {
-				return 0, err
-			}
-			stack = append(stack, res)
-		case variableType:
-			res, exists := vars[tkn.SValue]
-			if !exists {
-				return 0, fmt.Errorf("unknown variable '%s'", tkn.SValue)
-			}
-			stack = append(stack, res)
-		default:
-			return 0, fmt.Errorf("unknown token %d, %s, %f", tkn.Type, tkn.SValue, tkn.FValue)
+	if c.program == nil {
+		return 0, &NotPreparedError{}
+	}
+	return c.program.execute(vars)
+}
+
+// ExecuteContext executes prepared expression like Execute, but stops when ctx is done
+// and passes ctx to functions created by NewContextFunction.
+// Expression is always interpreted, so it is slower than Execute.
+func (c *Calc) ExecuteContext(ctx context.Context, vars map[string]float64) (float64, error) {
+	if c.program == nil {
+		return 0, &NotPreparedError{}
+	}
+	slots, err := c.program.slotsOf(vars)
+	if err != nil {
+		return 0, err
+	}
+	res, err := c.program.evaluateContext(ctx, &env{}, slots)
+	if err != nil {
+		return 0, err
+	}
+	return resultNumber(res)
+}
+
+// ExecuteSlots executes prepared expression with variables placed at indexes given by Layout
+func (c *Calc) ExecuteSlots(slots []float64) (float64, error) {
+	if c.program == nil {
+		return 0, &NotPreparedError{}
+	}
+	return c.program.executeSlots(slots)
+}
+
+// ExecuteResolver executes prepared expression with variables fetched lazily from resolver
+func (c *Calc) ExecuteResolver(vars Resolver) (float64, error) {
+	res, err := c.evaluate(&env{vars: vars}, nil)
+	if err != nil {
+		return 0, err
+	}
+	return resultNumber(res)
+}
+
+// ExecuteStruct executes prepared expression with variables resolved from fields of struct v.
+// See NewStructResolver for details.
+func (c *Calc) ExecuteStruct(v interface{}) (float64, error) {
+	return c.ExecuteResolver(NewStructResolver(v))
+}
+
+// ExecuteScript executes prepared expression and returns also numeric variables assigned by script
+func (c *Calc) ExecuteScript(vars map[string]float64) (float64, map[string]float64, error) {
+	if c.program == nil {
+		return 0, nil, &NotPreparedError{}
+	}
+	slots, err := c.program.slotsOf(vars)
+	if err != nil {
+		return 0, nil, err
+	}
+	e := &env{}
+	res, err := c.evaluate(e, slots)
+	if err != nil {
+		return 0, nil, err
+	}
+	f, err := resultNumber(res)
+	if err != nil {
+		return 0, nil, err
+	}
+	assigned := make(map[string]float64, len(e.assigned))
+	for name, v := range e.assigned {
+		if n, ok := v.(Number); ok {
+			assigned[name] = float64(n)
 		}
 	}
-	if len(stack) != 1 {
-		return 0, errors.New("invalid expression")
+	return f, assigned, nil
+}
+
+// Evaluate prepared expression with variables of any type supported by ValueOf,
+// for example []float64 variables become lists
+func (c *Calc) Evaluate(vars map[string]interface{}) (Value, error) {
+	return c.EvaluateResolver(ValuesResolver(vars))
+}
+
+// EvaluateResolver evaluates prepared expression with variables fetched lazily from resolver
+func (c *Calc) EvaluateResolver(vars Resolver) (Value, error) {
+	return c.evaluate(&env{vars: vars}, nil)
+}
+
+func (c *Calc) evaluate(e *env, slots []float64) (Value, error) {
+	if c.program == nil {
+		return nil, &NotPreparedError{}
+	}
+	return c.program.evaluate(e, slots)
+}
+
+func resultNumber(res Value) (float64, error) {
+	f, err := asNumber(res)
+	if err != nil {
+		return 0, fmt.Errorf("result: %w", err)
 	}
-	return stack[0], nil
+	return f, nil
 }
 
-// AddFunction adds custom function
+// AddFunction adds custom function. Function of same name with other count of arguments
+// stays available, calls are dispatched by count of arguments.
 func (c *Calc) AddFunction(cf *Function) {
+	if prev, ok := c.functions[cf.Name]; ok && prev.Places != cf.Places {
+		c.functions[overloadKey(cf.Name, prev.Places)] = prev
+	}
+	delete(c.functions, overloadKey(cf.Name, cf.Places))
 	c.functions[cf.Name] = cf
 }
 
+// Define adds function defined by expression like `hyp(a, b) = sqrt(a^2 + b^2)`.
+// Body of function can use only its own parameters, local temporaries, other functions and operators.
+// Definition is checked against limits set by SetLimits.
+func (c *Calc) Define(definition string) error {
+	t := c.newTokenizer(definition, c.limits)
+	if err := t.tokenize(); err != nil {
+		return err
+	}
+	fn, body, err := parseDefinition(t.tkns)
+	if err != nil {
+		return err
+	}
+	if fn.body, err = c.newParser(definition, body, c.limits).parseScript(); err != nil {
+		return err
+	}
+	if err := c.limits.check(fn.body, c.functions); err != nil {
+		return err
+	}
+	bound := map[string]bool{}
+	for _, name := range fn.params {
+		bound[name] = true
+	}
+	free := &program{root: fn.body, layout: map[string]int{}, functions: c.functions}
+	free.assignSlots(fn.body, bound)
+	if len(free.names) > 0 {
+		return fmt.Errorf("function '%s': %w", fn.Name, &UnknownVariableError{Name: free.names[0]})
+	}
+	fn.Fn = func(args ...float64) (float64, error) {
+		values := make([]Value, len(args))
+		for i, arg := range args {
+			values[i] = Number(arg)
+		}
+		p := &program{
+			functions:      c.functions,
+			operators:      c.operators,
+			recursionLimit: c.recursionLimit,
+			stepLimit:      c.stepLimit,
+			callLimit:      c.callLimit,
+		}
+		res, err := p.call(fn, values, &state{})
+		if err != nil {
+			return 0, err
+		}
+		return asNumber(res)
+	}
+	c.AddFunction(fn)
+	return nil
+}
+
+// SetRecursionLimit sets maximum nesting of defined function calls
+func (c *Calc) SetRecursionLimit(limit int) {
+	c.recursionLimit = limit
+}
+
+// SetNumericPolicy sets handling of division by zero and non-finite results for expressions
+// prepared afterwards. Substitute is used only by SubstitutePolicy.
+// Policy is not applied to functions and operators with ValueFn.
+func (c *Calc) SetNumericPolicy(policy NumericPolicy, substitute float64) {
+	c.numeric = numeric{policy: policy, substitute: substitute}
+}
+
+// SetFastMath allows Prepare to apply rewrites that are not exact for floats:
+// `x*0 = 0`, `x-x = 0`, `x/x = 1`, `x^0 = 1` and regrouping of constants like `x*2*3 = x*6`
+func (c *Calc) SetFastMath(enabled bool) {
+	c.fastMath = enabled
+}
+
+// SetLimits sets limits of expressions accepted by Prepare and Define
+func (c *Calc) SetLimits(limits Limits) {
+	c.limits = limits
+}
+
+// SetStepLimit sets maximum count of evaluated nodes per execution, 0 means no limit.
+// Expressions prepared with any limit are interpreted instead of compiled.
+func (c *Calc) SetStepLimit(limit int) {
+	c.stepLimit = limit
+}
+
+// SetCallLimit sets maximum count of function calls per execution, 0 means no limit
+func (c *Calc) SetCallLimit(limit int) {
+	c.callLimit = limit
+}
+
 // AddOperator adds custom operator
 func (c *Calc) AddOperator(op *Operator) {
 	c.operators[op.Op] = op
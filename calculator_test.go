@@ -20,7 +20,14 @@
 
 package executor
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
 
 func TestCalc(t *testing.T) {
 	funcs := []*Function{
@@ -93,3 +100,675 @@ func TestCalc2(t *testing.T) {
 		t.Errorf("Expected %f, actual %f", expected, actual)
 	}
 }
+
+func TestDefine(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddFunction(NewFunction("sqrt", func(args ...float64) (float64, error) {
+		return math.Sqrt(args[0]), nil
+	}, 1))
+	if err := c.Define("hyp(a, b) = sqrt(a^2 + b^2)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Define("double(a) = hyp(a, 0) * 2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Prepare("double(a) + hyp(3, 4)"); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := c.Execute(map[string]float64{"a": 10})
+	if err != nil {
+		t.Error(err)
+	}
+	if actual != 25 {
+		t.Errorf("Expected %f, actual %f", 25.0, actual)
+	}
+	if err := c.Define("leak(a) = a + b"); err == nil {
+		t.Error("Expected error for variable outside of parameters")
+	}
+	for _, def := range []string{"f(a, a) = a", "f(a,) = a", "f(a) a", "f(a) ="} {
+		if err := c.Define(def); err == nil {
+			t.Errorf("Expected error for %s", def)
+		}
+	}
+	if err := c.Define("loop(a) = loop(a)"); err != nil {
+		t.Fatal(err)
+	}
+	c.SetRecursionLimit(10)
+	if err := c.Prepare("loop(1)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Execute(nil); err != ErrRecursionLimit {
+		t.Errorf("Expected %v, got %v", ErrRecursionLimit, err)
+	}
+}
+
+func TestExecuteContext(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	var received context.Context
+	c.AddFunction(NewContextFunction("slow", func(ctx context.Context, args ...float64) (float64, error) {
+		received = ctx
+		return args[0], ctx.Err()
+	}, 1))
+	if err := c.Prepare("slow(x) + 1"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	actual, err := c.ExecuteContext(ctx, map[string]float64{"x": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != 3 || received != ctx {
+		t.Errorf("Expected 3 with context of execution, actual %f", actual)
+	}
+	if actual, err := c.Execute(map[string]float64{"x": 2}); err != nil || actual != 3 {
+		t.Errorf("Expected 3, actual %f, %v", actual, err)
+	}
+	cancel()
+	if _, err := c.ExecuteContext(ctx, map[string]float64{"x": 2}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+
+	c.SetStepLimit(5)
+	if err := c.Prepare("slow(x) + 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Execute(map[string]float64{"x": 2}); err != nil {
+		t.Error(err)
+	}
+	if err := c.Prepare("slow(x) + slow(x) + 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Execute(map[string]float64{"x": 2}); err != ErrStepLimit {
+		t.Errorf("Expected %v, got %v", ErrStepLimit, err)
+	}
+
+	c.SetStepLimit(0)
+	c.SetCallLimit(2)
+	if err := c.Define("twice(a) = slow(a) + slow(a)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Prepare("slow(x)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Execute(map[string]float64{"x": 2}); err != nil {
+		t.Error(err)
+	}
+	if err := c.Prepare("twice(x)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecuteContext(context.Background(), map[string]float64{"x": 2}); err != ErrCallLimit {
+		t.Errorf("Expected %v, got %v", ErrCallLimit, err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddOperators(LogicOperators)
+	c.AddFunction(NewFunction("abs", func(args ...float64) (float64, error) { return math.Abs(args[0]), nil }, 1))
+	c.AddFunction(NewFunction("exp", func(args ...float64) (float64, error) { return math.Exp(args[0]), nil }, 1))
+	c.SetLimits(Limits{MaxLength: 20, MaxTokens: 9, MaxDepth: 6, MaxLiteral: 1000})
+	if err := c.Prepare("abs(x - 10) * 2"); err != nil {
+		t.Fatal(err)
+	}
+	for _, expr := range []string{
+		"x + y + z + w + v + u + t",
+		"1+2+3+4+5+6",
+		"((((((x))))))",
+		"x * 10000",
+	} {
+		if err := c.Prepare(expr); !errors.Is(err, ErrLimit) {
+			t.Errorf("Expected %v for %s, got %v", ErrLimit, expr, err)
+		}
+	}
+	if err := c.Define("f(a) = a * 10000"); !errors.Is(err, ErrLimit) {
+		t.Errorf("Expected %v, got %v", ErrLimit, err)
+	}
+
+	c.SetLimits(Limits{})
+	for _, expr := range []string{
+		strings.Repeat("(", 20000) + "1" + strings.Repeat(")", 20000),
+		"1" + strings.Repeat("+1", 20000),
+		strings.Repeat("x ? 1 : ", 20000) + "0",
+		"x" + strings.Repeat("[0]", 20000),
+	} {
+		if err := c.Prepare(expr); !errors.Is(err, ErrLimit) {
+			t.Errorf("Expected %v for expression of %d bytes, got %v", ErrLimit, len(expr), err)
+		}
+	}
+	if err := c.Prepare("1" + strings.Repeat("+1", 5000)); err != nil {
+		t.Error(err)
+	}
+
+	limits := Limits{AllowedFunctions: []string{"abs"}, DeniedOperators: []string{"^"}}
+	if err := c.PrepareLimits("abs(x) * 2 + 1", limits); err != nil {
+		t.Error(err)
+	}
+	for _, expr := range []string{"exp(x)", "x ^ 2", "abs(x ^ 2)"} {
+		if err := c.PrepareLimits(expr, limits); !errors.Is(err, ErrNotAllowed) {
+			t.Errorf("Expected %v for %s, got %v", ErrNotAllowed, expr, err)
+		}
+	}
+
+	if err := c.Define("g(a) = exp(a)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Define("h(n) = n <= 0 ? 0 : h(n - 1) + g(n)"); err != nil {
+		t.Fatal(err)
+	}
+	denied := Limits{DeniedFunctions: []string{"exp"}}
+	for _, expr := range []string{"g(1)", "h(3)"} {
+		if err := c.PrepareLimits(expr, denied); !errors.Is(err, ErrNotAllowed) {
+			t.Errorf("Expected %v for %s, got %v", ErrNotAllowed, expr, err)
+		}
+	}
+	if err := c.PrepareLimits("h(3)", Limits{DeniedFunctions: []string{"abs"}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	errFailed := errors.New("lookup failed")
+	c.AddFunction(NewFunction("lookup", func(args ...float64) (float64, error) {
+		if args[0] < 0 {
+			return 0, errFailed
+		}
+		return args[0], nil
+	}, 1))
+	var notPrepared *NotPreparedError
+	if _, err := c.Execute(nil); !errors.As(err, &notPrepared) {
+		t.Errorf("Expected NotPreparedError, got %v", err)
+	}
+	if err := c.Prepare("x + lookup(y)"); err != nil {
+		t.Fatal(err)
+	}
+	var unknownVariable *UnknownVariableError
+	if _, err := c.Execute(map[string]float64{"x": 1}); !errors.As(err, &unknownVariable) || unknownVariable.Name != "y" {
+		t.Errorf("Expected unknown variable y, got %v", err)
+	}
+	var functionErr *FunctionError
+	_, err := c.Execute(map[string]float64{"x": 1, "y": -1})
+	if !errors.As(err, &functionErr) || functionErr.Func != "lookup" || functionErr.Pos != 4 || !errors.Is(err, errFailed) {
+		t.Errorf("Expected error of lookup at 4, got %v", err)
+	}
+	var evalErr *EvalError
+	if !errors.As(err, &evalErr) || evalErr.Text != "lookup(y)" || evalErr.Pos != 4 {
+		t.Errorf("Expected error at lookup(y), got %v", err)
+	}
+	if err := c.Define("price(q) = 2 * lookup(q - 10)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Prepare("1 + (x * price(qty))"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Execute(map[string]float64{"x": 1, "qty": 3})
+	expected := "price(qty) at 9: lookup(q - 10) at 15: function 'lookup': lookup failed"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+	if !errors.As(err, &evalErr) || !errors.As(evalErr.Err, &evalErr) || evalErr.Text != "lookup(q - 10)" {
+		t.Errorf("Expected call chain to lookup(q - 10), got %v", err)
+	}
+	if err := c.Prepare("lookup(y, 1)"); err != nil {
+		t.Fatal(err)
+	}
+	var arity *ArityError
+	if _, err := c.Execute(map[string]float64{"y": 1}); !errors.As(err, &arity) || arity.Want != 1 || arity.Got != 2 {
+		t.Errorf("Expected arity error, got %v", err)
+	}
+	if err := c.Prepare("missing(x)"); err != nil {
+		t.Fatal(err)
+	}
+	var unknownFunction *UnknownFunctionError
+	if _, err := c.Execute(map[string]float64{"x": 1}); !errors.As(err, &unknownFunction) || unknownFunction.Name != "missing" {
+		t.Errorf("Expected unknown function missing, got %v", err)
+	}
+}
+
+func TestNumericPolicy(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddOperators(LogicOperators)
+	c.AddFunction(NewFunction("log", func(args ...float64) (float64, error) { return math.Log(args[0]), nil }, 1))
+	vars := map[string]float64{"x": 1, "y": 0}
+	if err := c.Prepare("x / y + 1"); err != nil {
+		t.Fatal(err)
+	}
+	if actual, err := c.Execute(vars); err != nil || !math.IsInf(actual, 1) {
+		t.Errorf("Expected +Inf, actual %f, %v", actual, err)
+	}
+
+	c.SetNumericPolicy(DivisionByZeroPolicy, 0)
+	if err := c.Prepare("x / y + 1"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.Execute(vars)
+	var evalErr *EvalError
+	if !errors.Is(err, ErrDivisionByZero) || !errors.As(err, &evalErr) || evalErr.Text != "x / y" {
+		t.Errorf("Expected division by zero at x / y, got %v", err)
+	}
+	if err := c.Prepare("log(y) < 0"); err != nil {
+		t.Fatal(err)
+	}
+	if actual, err := c.Execute(vars); err != nil || actual != 1 {
+		t.Errorf("Expected 1, actual %f, %v", actual, err)
+	}
+
+	c.SetNumericPolicy(NonFinitePolicy, 0)
+	if err := c.Prepare("x + log(y) < 0"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Execute(vars)
+	if !errors.Is(err, ErrNonFinite) || !errors.As(err, &evalErr) || evalErr.Text != "log(y)" {
+		t.Errorf("Expected non-finite result at log(y), got %v", err)
+	}
+	if err := c.Prepare("x / y"); err != nil {
+		t.Fatal(err)
+	}
+	out := make([]float64, 2)
+	err = c.ExecuteBatch(map[string][]float64{"x": {1, 1}, "y": {2, 0}}, out)
+	var batchErr BatchError
+	if !errors.As(err, &batchErr) || batchErr[0].Row != 1 || !errors.Is(batchErr[0], ErrNonFinite) {
+		t.Errorf("Expected %v at row 1, got %v", ErrNonFinite, err)
+	}
+
+	c.SetNumericPolicy(SubstitutePolicy, -1)
+	if err := c.Prepare("x / y + 1"); err != nil {
+		t.Fatal(err)
+	}
+	if actual, err := c.Execute(vars); err != nil || actual != 0 {
+		t.Errorf("Expected 0, actual %f, %v", actual, err)
+	}
+}
+
+func TestScript(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddOperators(LogicOperators)
+	if err := c.Prepare("let rate = 0.2; tax = price * rate; discount = tax > 50 ? 10 : 0; price + tax - discount"); err != nil {
+		t.Fatal(err)
+	}
+	actual, assigned, err := c.ExecuteScript(map[string]float64{"price": 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != 350 {
+		t.Errorf("Expected %f, actual %f", 350.0, actual)
+	}
+	expected := map[string]float64{"tax": 60, "discount": 10}
+	if len(assigned) != len(expected) {
+		t.Errorf("Expected %v, actual %v", expected, assigned)
+	}
+	for name, value := range expected {
+		if assigned[name] != value {
+			t.Errorf("Expected %s = %f, actual %f", name, value, assigned[name])
+		}
+	}
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{"10 - 2 - 3", 5},
+		{"2 ^ 3 ^ 2", 512},
+		{"-x ^ 2", -9},
+		{"2 * -x", -6},
+		{"x > 1 ? x < 5 ? 1 : 2 : 3", 1},
+		{"y1 = x * 2; y1 + 1", 7},
+		{"fact(x)", 6},
+	}
+	if err := c.Define("fact(n) = n <= 1 ? 1 : n * fact(n - 1)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		if err := c.Prepare(test.expression); err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		actual, err := c.Execute(map[string]float64{"x": 3})
+		if err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+		}
+		if actual != test.expected {
+			t.Errorf("%s: expected %f, actual %f", test.expression, test.expected, actual)
+		}
+	}
+	for _, expression := range []string{"(1 + 2", "1 + 2)", "x ? 1", "1 +", ""} {
+		if err := c.Prepare(expression); err == nil {
+			t.Errorf("Expected error for %s", expression)
+		}
+	}
+}
+
+func TestLists(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddOperators(LogicOperators)
+	c.AddFunctions(ListFunctions)
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"[1, 2, 3]", "[1, 2, 3]"},
+		{"[]", "[]"},
+		{"xs[1] + [[5, 6]][0][1]", "26"},
+		{"map(xs, x => x * 2)", "[20, 40, 60]"},
+		{"filter(xs, x => x > 15)", "[20, 30]"},
+		{"reduce(xs, 1, (acc, x) => acc + x)", "61"},
+		{"any(xs, x => x > 25) + all(xs, x => x > 25)", "1"},
+		{"sum(xs) / len(xs)", "20"},
+		{"sort([3, -1, 2])", "[-1, 2, 3]"},
+		{"k = 3; map(xs, x => x * k)", "[30, 60, 90]"},
+		{"double = x => x * 2; double(rate)", "1"},
+		{"sum(map(items, item => item[0] * item[1]))", "35"},
+		{"xs + 1", "[11, 21, 31]"},
+	}
+	vars := map[string]interface{}{
+		"xs":    []float64{10, 20, 30},
+		"rate":  0.5,
+		"items": []interface{}{[]float64{2, 10}, []float64{3, 5}},
+	}
+	for _, test := range tests {
+		if err := c.Prepare(test.expression); err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		actual, err := c.Evaluate(vars)
+		if err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		if actual.String() != test.expected {
+			t.Errorf("%s: expected %s, actual %s", test.expression, test.expected, actual)
+		}
+	}
+	for _, expression := range []string{"xs[3]", "xs[0.5]", "map(xs, (a, b) => a)", "xs + [1, 2]", "f = x => f(x); f(1)"} {
+		if err := c.Prepare(expression); err != nil {
+			t.Errorf("%s: %v", expression, err)
+			continue
+		}
+		if _, err := c.Evaluate(vars); err == nil {
+			t.Errorf("Expected error for %s", expression)
+		}
+	}
+
+	if err := c.Prepare("f = x => x + y; y = 3; f(1)"); err != nil {
+		t.Fatal(err)
+	}
+	executed, err := c.Execute(map[string]float64{"y": 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluated, err := c.Evaluate(map[string]interface{}{"y": 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if executed != 4 || evaluated.String() != "4" {
+		t.Errorf("Expected lambda to see assigned y = 3 in both paths, executed %f, evaluated %s", executed, evaluated)
+	}
+}
+
+func TestLinalg(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddOperators(LinalgOperators)
+	c.AddFunctions(LinalgFunctions)
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"a * 2 - [1, 1, 1]", "[1, 3, 5]"},
+		{"-m", "[[-2, -1], [-1, -3]]"},
+		{"m + m", "[[4, 2], [2, 6]]"},
+		{"m @ [1, 2]", "[4, 7]"},
+		{"[1, 2] @ m", "[4, 7]"},
+		{"m @ [[1, 0], [0, 2]]", "[[2, 2], [1, 6]]"},
+		{"a @ b", "3"},
+		{"dot(a, b)", "3"},
+		{"cross(a, b)", "[-3, 3, -1]"},
+		{"norm([3, 4])", "5"},
+		{"transpose([[1, 2, 3], [4, 5, 6]])", "[[1, 4], [2, 5], [3, 6]]"},
+		{"det(m)", "5"},
+		{"inv([[2, 0], [0, 4]])", "[[0.5, 0], [0, 0.25]]"},
+		{"solve(m, [3, 5])", "[0.8, 1.4]"},
+	}
+	vars := map[string]interface{}{
+		"a": []float64{1, 2, 3},
+		"b": []float64{1, 1, 0},
+		"m": [][]float64{{2, 1}, {1, 3}},
+	}
+	for _, test := range tests {
+		if err := c.Prepare(test.expression); err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		actual, err := c.Evaluate(vars)
+		if err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		if actual.String() != test.expected {
+			t.Errorf("%s: expected %s, actual %s", test.expression, test.expected, actual)
+		}
+	}
+	for _, expression := range []string{"a + [1, 2]", "m @ a", "cross(a, [1, 2])", "det(transpose(a))", "[[]] @ []", "[] @ [[1]]", "[[1]] @ [[]]"} {
+		if err := c.Prepare(expression); err != nil {
+			t.Errorf("%s: %v", expression, err)
+			continue
+		}
+		if _, err := c.Evaluate(vars); !errors.Is(err, ErrShapeMismatch) {
+			t.Errorf("%s: expected shape mismatch, got %v", expression, err)
+		}
+	}
+	if err := c.Prepare("inv([[1, 2], [2, 4]])"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Evaluate(nil); !errors.Is(err, ErrSingularMatrix) {
+		t.Errorf("Expected %v, got %v", ErrSingularMatrix, err)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	if err := c.Prepare("price * qty"); err != nil {
+		t.Fatal(err)
+	}
+	var resolved []string
+	fields := map[string]float64{"price": 2.5, "qty": 4, "weight": 10}
+	r := ResolverFunc(func(name string) (Value, bool, error) {
+		resolved = append(resolved, name)
+		v, ok := fields[name]
+		return Number(v), ok, nil
+	})
+	actual, err := c.ExecuteResolver(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != 10 {
+		t.Errorf("Expected %f, actual %f", 10.0, actual)
+	}
+	if len(resolved) != 2 {
+		t.Errorf("Expected only used variables to be resolved, got %v", resolved)
+	}
+	errDB := errors.New("connection refused")
+	_, err = c.ExecuteResolver(ResolverFunc(func(name string) (Value, bool, error) {
+		return nil, false, errDB
+	}))
+	if !errors.Is(err, errDB) || !strings.Contains(err.Error(), "price") {
+		t.Errorf("Expected wrapped error with variable name, got %v", err)
+	}
+
+	c.AddFunctions(MathFunctions)
+	if err := c.Prepare("abs(price) * qty"); err != nil {
+		t.Fatal(err)
+	}
+	resolved = nil
+	if _, err := c.ExecuteResolver(r); err != nil {
+		t.Fatal(err)
+	}
+	if len(resolved) != 2 {
+		t.Errorf("Expected function names not to be resolved, got %v", resolved)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddFunctions(ListFunctions)
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"qty": 3, "items": [{"price": 2.5}, {"price": 4, "tags": {"vip": 1}}]}`), &payload); err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]interface{}{"payload": payload}
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{"payload.items[0].price * payload.qty", 7.5},
+		{"payload.items[1].tags.vip", 1},
+		{"sum(map(payload.items, item => item.price))", 6.5},
+		{"len(payload.items) + 0.5", 2.5},
+	}
+	for _, test := range tests {
+		if err := c.Prepare(test.expression); err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		actual, err := c.Evaluate(vars)
+		if err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		if actual != Number(test.expected) {
+			t.Errorf("%s: expected %f, actual %s", test.expression, test.expected, actual)
+		}
+	}
+	errorTests := []struct {
+		expression string
+		message    string
+	}{
+		{"payload.items[0].tags.vip", "missing key 'tags' at 'payload.items[0].tags.vip'"},
+		{"payload.qtty", "missing key 'qtty' at 'payload.qtty'"},
+		{"payload.qty.value", "expected map at 'payload.qty'"},
+	}
+	for _, test := range errorTests {
+		if err := c.Prepare(test.expression); err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		if _, err := c.Evaluate(vars); err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%s: expected error %q, got %v", test.expression, test.message, err)
+		}
+	}
+}
+
+func TestSlots(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddOperators(LogicOperators)
+	c.AddFunctions(ListFunctions)
+	if err := c.Prepare("let k = 2; total = price * qty * k; total > limit ? sum(map([1, 2], x => x * price)) : qty"); err != nil {
+		t.Fatal(err)
+	}
+	layout := c.Layout()
+	expected := map[string]int{"price": 0, "qty": 1, "limit": 2}
+	if len(layout) != len(expected) {
+		t.Errorf("Expected layout %v, actual %v", expected, layout)
+	}
+	for name, slot := range expected {
+		if layout[name] != slot {
+			t.Errorf("Expected %s at slot %d, actual %d", name, slot, layout[name])
+		}
+	}
+	actual, err := c.ExecuteSlots([]float64{10, 3, 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != 30 {
+		t.Errorf("Expected %f, actual %f", 30.0, actual)
+	}
+	if _, err := c.ExecuteSlots([]float64{10, 3}); err == nil {
+		t.Error("Expected error for wrong count of slots")
+	}
+	if _, err := c.Execute(map[string]float64{"price": 10, "qty": 3}); err == nil || err.Error() != "unknown variable 'limit'" {
+		t.Errorf("Expected unknown variable 'limit', got %v", err)
+	}
+}
+
+func TestCompiled(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddOperators(LogicOperators)
+	c.AddFunction(NewFunction("max", func(args ...float64) (float64, error) {
+		return math.Max(args[0], args[1]), nil
+	}, 2))
+	if err := c.Prepare("x > 1 ? max(x * 2, max(y, -z)) : 0"); err != nil {
+		t.Fatal(err)
+	}
+	if c.program.compiled == nil {
+		t.Fatal("Expected numeric expression to be compiled")
+	}
+	vars := map[string]float64{"x": 2, "y": 10, "z": -20}
+	slots := []float64{2, 10, -20}
+	actual, err := c.Execute(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interpreted, err := c.evaluate(&env{}, slots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != 20 || interpreted != Number(actual) {
+		t.Errorf("Expected %f, actual %f, interpreted %s", 20.0, actual, interpreted)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { c.ExecuteSlots(slots) }); allocs > 0 {
+		t.Errorf("Expected no allocations, got %f", allocs)
+	}
+	if err := c.Prepare("sum([x])"); err != nil {
+		t.Fatal(err)
+	}
+	if c.program.compiled != nil {
+		t.Error("Expected expression with lists to be interpreted")
+	}
+}
+
+func benchmarkCalc(b *testing.B) (*Calc, map[string]float64, []float64) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddOperators(LogicOperators)
+	c.AddFunction(NewFunction("max", func(args ...float64) (float64, error) {
+		return math.Max(args[0], args[1]), nil
+	}, 2))
+	if err := c.Prepare("price * qty * (1 - discount) > 100 ? max(price * qty * (1 - discount) - 10, 100) : price * qty"); err != nil {
+		b.Fatal(err)
+	}
+	return c, map[string]float64{"price": 12.5, "qty": 10, "discount": 0.1}, []float64{12.5, 10, 0.1}
+}
+
+func BenchmarkExecute(b *testing.B) {
+	c, vars, _ := benchmarkCalc(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.Execute(vars)
+	}
+}
+
+func BenchmarkExecuteSlots(b *testing.B) {
+	c, _, slots := benchmarkCalc(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.ExecuteSlots(slots)
+	}
+}
+
+func BenchmarkExecuteInterpreted(b *testing.B) {
+	c, _, slots := benchmarkCalc(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.evaluate(&env{}, slots)
+	}
+}
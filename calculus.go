@@ -0,0 +1,274 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// CalculusFunctions is default set of functions over expressions of bound variable:
+// `integrate(x^2, x, 0, 1)`, `sum(i^2, i, 1, 10)`, `prod(i, i, 1, 5)` and `limit(sin(x) / x, x, 0)`.
+// Its `sum` takes 4 arguments, so it doesn't replace `sum` of lists from ListFunctions.
+var CalculusFunctions = []*Function{
+	NewBindingFunction("integrate", func(args ...Value) (Value, error) {
+		f, a, b, err := boundArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		res, _, err := integrate(f, a, b, integrateTolerance, integrateIntervals)
+		return Number(res), err
+	}, 4),
+	NewBindingFunction("sum", func(args ...Value) (Value, error) {
+		return series(args, 0, func(acc, v float64) float64 { return acc + v })
+	}, 4),
+	NewBindingFunction("prod", func(args ...Value) (Value, error) {
+		return series(args, 1, func(acc, v float64) float64 { return acc * v })
+	}, 4),
+	NewBindingFunction("limit", func(args ...Value) (Value, error) {
+		l, err := asLambda(args[0], 1)
+		if err != nil {
+			return nil, err
+		}
+		a, err := asNumber(args[1])
+		if err != nil {
+			return nil, err
+		}
+		res, err := limit(numberFn(l), a)
+		return Number(res), err
+	}, 3),
+}
+
+// integrateTolerance is relative tolerance of integrate
+// integrateIntervals is maximal count of subintervals of integrate
+// seriesTerms is maximal count of terms of sum and prod
+// maxExactInteger is maximal bound of sum and prod, every integer up to it is exact float64
+const (
+	integrateTolerance = 1e-8
+	integrateIntervals = 1000
+	seriesTerms        = 10000000
+	maxExactInteger    = 1 << 53
+)
+
+// numberFn returns lambda of one number as Go function
+func numberFn(l *Lambda) func(x float64) (float64, error) {
+	return func(x float64) (float64, error) {
+		res, err := l.Call(Number(x))
+		if err != nil {
+			return 0, err
+		}
+		return asNumber(res)
+	}
+}
+
+// boundArgs returns bound expression and bounds of integrate, sum and prod
+func boundArgs(args []Value) (func(x float64) (float64, error), float64, float64, error) {
+	l, err := asLambda(args[0], 1)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	a, err := asNumber(args[1])
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	b, err := asNumber(args[2])
+	return numberFn(l), a, b, err
+}
+
+// series folds values of expression for integers from first to last bound inclusive
+func series(args []Value, acc float64, fold func(acc, v float64) float64) (Value, error) {
+	f, from, to, err := boundArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if from != math.Trunc(from) || to != math.Trunc(to) {
+		return nil, fmt.Errorf("bounds must be integers, got %g and %g", from, to)
+	}
+	if math.Abs(from) > maxExactInteger || math.Abs(to) > maxExactInteger {
+		return nil, fmt.Errorf("bounds must not exceed %g, got %g and %g", float64(maxExactInteger), from, to)
+	}
+	n := int64(to) - int64(from) + 1
+	if n > seriesTerms {
+		return nil, fmt.Errorf("more than %d terms", seriesTerms)
+	}
+	for k := int64(0); k < n; k++ {
+		v, err := f(from + float64(k))
+		if err != nil {
+			return nil, err
+		}
+		acc = fold(acc, v)
+	}
+	return Number(acc), nil
+}
+
+// Nodes and weights of 15-point Gauss-Kronrod rule and embedded 7-point Gauss rule on [-1, 1].
+// Gauss rule uses nodes with odd indexes.
+var (
+	kronrodNodes = [8]float64{
+		0.991455371120812639206854697526329, 0.949107912342758524526189684047851,
+		0.864864423359769072789712788640926, 0.741531185599394439863864773280788,
+		0.586087235467691130294144845693013, 0.405845151377397166906606412076961,
+		0.207784955007898467600689403773245, 0,
+	}
+	kronrodWeights = [8]float64{
+		0.022935322010529224963732008058970, 0.063092092629978553290700663189204,
+		0.104790010322250183839876322541518, 0.140653259715525918745189590510238,
+		0.169004726639267902826583426598550, 0.190350578064785409913256402421014,
+		0.204432940075298892414161999234649, 0.209482141084727828012999174891714,
+	}
+	gaussWeights = [4]float64{
+		0.129484966168869693270611432679082, 0.279705391489276667901467771423780,
+		0.381830050505118944950369775488975, 0.417959183673469387755102040816327,
+	}
+)
+
+// interval is part of integration range with its integral and error estimate
+type interval struct {
+	a, b       float64
+	value, err float64
+}
+
+// kronrod integrates f over [a, b] by Gauss-Kronrod rule, error is difference with Gauss rule
+func kronrod(f func(x float64) (float64, error), a, b float64) (interval, error) {
+	center, half := (a+b)/2, (b-a)/2
+	fc, err := f(center)
+	if err != nil {
+		return interval{}, err
+	}
+	k, g := fc*kronrodWeights[7], fc*gaussWeights[3]
+	for i := 0; i < 7; i++ {
+		dx := half * kronrodNodes[i]
+		f1, err := f(center - dx)
+		if err != nil {
+			return interval{}, err
+		}
+		f2, err := f(center + dx)
+		if err != nil {
+			return interval{}, err
+		}
+		k += kronrodWeights[i] * (f1 + f2)
+		if i%2 == 1 {
+			g += gaussWeights[i/2] * (f1 + f2)
+		}
+	}
+	return interval{a: a, b: b, value: k * half, err: math.Abs((k - g) * half)}, nil
+}
+
+// integrate integrates f over [a, b] by adaptive Gauss-Kronrod quadrature.
+// Interval with largest error is bisected until total error is below tol relative to result.
+// It returns integral and its error estimate.
+func integrate(f func(x float64) (float64, error), a, b, tol float64, maxIntervals int) (float64, float64, error) {
+	if a == b {
+		return 0, 0, nil
+	}
+	if a > b {
+		res, errEstimate, err := integrate(f, b, a, tol, maxIntervals)
+		return -res, errEstimate, err
+	}
+	first, err := kronrod(f, a, b)
+	if err != nil {
+		return 0, 0, err
+	}
+	intervals := []interval{first}
+	for {
+		var value, errEstimate float64
+		for _, in := range intervals {
+			value += in.value
+			errEstimate += in.err
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return 0, 0, &ConvergenceError{Reason: "integral is not finite", Iterations: len(intervals), X: value, Residual: errEstimate}
+		}
+		if errEstimate <= tol*math.Max(1, math.Abs(value)) {
+			return value, errEstimate, nil
+		}
+		if len(intervals) >= maxIntervals {
+			return 0, 0, &ConvergenceError{Reason: "error estimate exceeds tolerance", Iterations: len(intervals), X: value, Residual: errEstimate}
+		}
+		sort.Slice(intervals, func(i, j int) bool { return intervals[i].err > intervals[j].err })
+		worst := intervals[0]
+		mid := (worst.a + worst.b) / 2
+		if worst.b-worst.a <= 1e-15*math.Max(1, math.Abs(mid)) {
+			return 0, 0, &ConvergenceError{Reason: fmt.Sprintf("integrand is singular near %g", mid), Iterations: len(intervals), X: value, Residual: errEstimate}
+		}
+		left, err := kronrod(f, worst.a, mid)
+		if err != nil {
+			return 0, 0, err
+		}
+		right, err := kronrod(f, mid, worst.b)
+		if err != nil {
+			return 0, 0, err
+		}
+		intervals[0] = left
+		intervals = append(intervals, right)
+	}
+}
+
+// limit returns limit of f at a as extrapolation of its values at both sides of a
+func limit(f func(x float64) (float64, error), a float64) (float64, error) {
+	left, err := extrapolate(f, a, -1)
+	if err != nil {
+		return 0, err
+	}
+	right, err := extrapolate(f, a, 1)
+	if err != nil {
+		return 0, err
+	}
+	if math.Abs(left-right) > 1e-6*math.Max(1, math.Max(math.Abs(left), math.Abs(right))) {
+		return 0, fmt.Errorf("no limit at %g: %g at left, %g at right", a, left, right)
+	}
+	return (left + right) / 2, nil
+}
+
+// extrapolate returns limit of f at a from one side by Richardson extrapolation of values
+// at a + side*h for halving steps h
+func extrapolate(f func(x float64) (float64, error), a, side float64) (float64, error) {
+	const levels = 12
+	h := 0.1 * math.Max(1, math.Abs(a))
+	var prev []float64
+	best, bestDiff := math.NaN(), math.Inf(1)
+	for k := 0; k < levels; k++ {
+		v, err := f(a + side*h)
+		if err != nil {
+			return 0, err
+		}
+		row := []float64{v}
+		for j := 1; j <= k; j++ {
+			row = append(row, row[j-1]+(row[j-1]-prev[j-1])/(math.Pow(2, float64(j))-1))
+		}
+		if k > 0 {
+			diff := math.Abs(row[k] - prev[k-1])
+			if diff < bestDiff {
+				best, bestDiff = row[k], diff
+			}
+			if diff <= 1e-12*math.Max(1, math.Abs(row[k])) {
+				return row[k], nil
+			}
+		}
+		prev = row
+		h /= 2
+	}
+	if !(bestDiff <= 1e-6*math.Max(1, math.Abs(best))) {
+		return 0, &ConvergenceError{Reason: "values don't approach limit", Iterations: levels, X: a, Residual: bestDiff}
+	}
+	return best, nil
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestCalculusFunctions(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddFunctions(MathFunctions)
+	c.AddFunctions(CalculusFunctions)
+	if err := c.Define("area(k) = integrate(k * t, t, 0, 2)"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expression string
+		vars       map[string]float64
+		want       float64
+	}{
+		{"integrate(x^2, x, 0, 1)", nil, 1.0 / 3},
+		{"integrate(sin(x), x, 3.141592653589793, 0)", nil, -2},
+		{"integrate(exp(-x^2), x, -5, 5)", nil, math.Sqrt(math.Pi)},
+		{"integrate(sqrt(x), x, 0, 1)", nil, 2.0 / 3},
+		{"integrate(a * x, x, 0, 2) + x", map[string]float64{"a": 3, "x": 1}, 7},
+		{"area(3)", nil, 6},
+		{"sum(i^2, i, 1, 10)", nil, 385},
+		{"sum(i, i, 1, 0)", nil, 0},
+		{"sum(sum(i * j, j, 1, 3), i, 1, 2)", nil, 18},
+		{"prod(i, i, 1, 5)", nil, 120},
+		{"limit(sin(x) / x, x, 0)", nil, 1},
+		{"limit((x^2 - 1) / (x - 1), x, 1)", nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			if err := c.Prepare(tt.expression); err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.Execute(tt.vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Execute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if err := c.Prepare("integrate(a * x, x, 0, 2)"); err != nil {
+		t.Fatal(err)
+	}
+	if layout := c.Layout(); len(layout) != 1 || layout["a"] != 0 {
+		t.Errorf("expected only free variable a, got %v", layout)
+	}
+	c.AddFunctions(ListFunctions)
+	for i := 0; i < 2; i++ {
+		if err := c.Prepare("sum(xs) + sum(i, i, 1, 3)"); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := c.Evaluate(map[string]interface{}{"xs": []float64{1, 2}}); err != nil || got != Number(9) {
+			t.Errorf("expected sum of list and sum of series 9, got %v, %v", got, err)
+		}
+		c.AddFunctions(CalculusFunctions)
+	}
+}
+
+func TestCalculusFunctionsErrors(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddFunctions(CalculusFunctions)
+	var failed *FunctionError
+	for _, expression := range []string{"sum(i, 2, 1, 3)", "sum(i, i, 1, 2.5)", "sum(0, i, 9007199254740992, 9007199254740994)", "prod(1, i, 0, 1000000000000)", "limit(1 / x, x, 0)", "integrate(y, x, 0, 1)"} {
+		if err := c.Prepare(expression); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Evaluate(map[string]interface{}{"y": List{}}); !errors.As(err, &failed) {
+			t.Errorf("%s: expected FunctionError, got %v", expression, err)
+		}
+	}
+	if err := c.Prepare("integrate(1 / x^2, x, -1, 1)"); err != nil {
+		t.Fatal(err)
+	}
+	var convergence *ConvergenceError
+	if _, err := c.Execute(nil); !errors.As(err, &convergence) {
+		t.Errorf("expected ConvergenceError, got %v", err)
+	}
+	c.SetStepLimit(1000)
+	if err := c.Prepare("sum(i, i, 1, 1000000)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Execute(nil); !errors.Is(err, ErrStepLimit) {
+		t.Errorf("expected ErrStepLimit, got %v", err)
+	}
+}
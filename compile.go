@@ -0,0 +1,215 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"fmt"
+	"sync"
+)
+
+// compiled is numeric expression compiled to tree of closures.
+// Operators and functions are resolved once, arguments of functions are placed
+// at fixed offsets of reusable stack, so execution doesn't allocate memory.
+type compiled struct {
+	fn     evalFn
+	frames sync.Pool
+}
+
+type evalFn func(f *frame) (float64, error)
+
+// frame holds variables and arguments of one execution
+type frame struct {
+	slots []float64
+	stack []float64
+	cache []float64 // results of shared nodes
+	done  []bool    // cached results of shared nodes
+}
+
+// reset clears cached results of previous execution
+func (f *frame) reset() {
+	for i := range f.done {
+		f.done[i] = false
+	}
+}
+
+// compile returns nil if expression uses anything except numbers, free variables,
+// operators, Go functions and conditions
+func (p *program) compile() *compiled {
+	size := 0
+	fn := p.compileNode(p.root, 0, &size)
+	if fn == nil {
+		return nil
+	}
+	res := &compiled{fn: fn}
+	res.frames.New = func() interface{} {
+		return &frame{
+			slots: make([]float64, len(p.names)),
+			stack: make([]float64, size),
+			cache: make([]float64, p.stats.Shared),
+			done:  make([]bool, p.stats.Shared),
+		}
+	}
+	return res
+}
+
+// compileNode compiles node, sp is offset of free part of stack.
+// Result of shared node is computed once per execution.
+func (p *program) compileNode(n *node, sp int, size *int) evalFn {
+	fn := p.compileExpr(n, sp, size)
+	if fn == nil || n.Cache == 0 {
+		return fn
+	}
+	i := n.Cache - 1
+	return func(f *frame) (float64, error) {
+		if f.done[i] {
+			return f.cache[i], nil
+		}
+		v, err := fn(f)
+		if err != nil {
+			return 0, err
+		}
+		f.cache[i], f.done[i] = v, true
+		return v, nil
+	}
+}
+
+func (p *program) compileExpr(n *node, sp int, size *int) evalFn {
+	switch n.Type {
+	case literalNode:
+		v := n.FValue
+		return func(f *frame) (float64, error) { return v, nil }
+	case variableNode:
+		if n.Slot < 0 {
+			return nil
+		}
+		slot := n.Slot
+		return func(f *frame) (float64, error) { return f.slots[slot], nil }
+	case negNode:
+		arg := p.compileNode(n.Args[0], sp, size)
+		if arg == nil {
+			return nil
+		}
+		return func(f *frame) (float64, error) {
+			v, err := arg(f)
+			return -v, err
+		}
+	case operatorNode:
+		op, ok := p.operators[n.SValue]
+		if !ok || op.ValueFn != nil {
+			return nil
+		}
+		left, right := p.compileNode(n.Args[0], sp, size), p.compileNode(n.Args[1], sp, size)
+		if left == nil || right == nil {
+			return nil
+		}
+		fn, name := op.Fn, op.Op
+		return func(f *frame) (float64, error) {
+			a, err := left(f)
+			if err != nil {
+				return 0, err
+			}
+			b, err := right(f)
+			if err != nil {
+				return 0, err
+			}
+			res, err := fn(a, b)
+			if err != nil {
+				return 0, newEvalError(n, fmt.Errorf("operator '%s': %w", name, err))
+			}
+			return res, nil
+		}
+	case functionNode:
+		function, ok := lookupFunction(p.functions, n.SValue, len(n.Args))
+		if !ok || function.ValueFn != nil || function.body != nil || function.Places != len(n.Args) {
+			return nil
+		}
+		places := len(n.Args)
+		if sp+places > *size {
+			*size = sp + places
+		}
+		args := make([]evalFn, places)
+		for i, arg := range n.Args {
+			if args[i] = p.compileNode(arg, sp+places, size); args[i] == nil {
+				return nil
+			}
+		}
+		fn, name := function.Fn, function.Name
+		return func(f *frame) (float64, error) {
+			stack := f.stack[sp : sp+places]
+			for i, arg := range args {
+				v, err := arg(f)
+				if err != nil {
+					return 0, err
+				}
+				stack[i] = v
+			}
+			res, err := fn(stack...)
+			if err != nil {
+				return 0, newEvalError(n, &FunctionError{Func: name, Pos: n.Pos, Err: err})
+			}
+			return res, nil
+		}
+	case conditionNode:
+		cond := p.compileNode(n.Args[0], sp, size)
+		a, b := p.compileNode(n.Args[1], sp, size), p.compileNode(n.Args[2], sp, size)
+		if cond == nil || a == nil || b == nil {
+			return nil
+		}
+		return func(f *frame) (float64, error) {
+			v, err := cond(f)
+			if err != nil {
+				return 0, err
+			}
+			if v != 0 {
+				return a(f)
+			}
+			return b(f)
+		}
+	}
+	return nil
+}
+
+// execute runs compiled expression with variables from slots
+func (cp *compiled) execute(slots []float64) (float64, error) {
+	f := cp.frames.Get().(*frame)
+	own := f.slots
+	f.slots = slots
+	f.reset()
+	res, err := cp.fn(f)
+	f.slots = own
+	cp.frames.Put(f)
+	return res, err
+}
+
+// executeMap runs compiled expression with variables from map
+func (cp *compiled) executeMap(names []string, vars map[string]float64) (float64, error) {
+	f := cp.frames.Get().(*frame)
+	defer cp.frames.Put(f)
+	f.reset()
+	for i, name := range names {
+		v, ok := vars[name]
+		if !ok {
+			return 0, &UnknownVariableError{Name: name}
+		}
+		f.slots[i] = v
+	}
+	return cp.fn(f)
+}
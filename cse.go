@@ -0,0 +1,84 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+// Stats describes prepared expression
+type Stats struct {
+	Deduplicated int // count of repeated sub-expressions replaced by shared ones
+	Shared       int // count of shared sub-expressions evaluated once per execution
+}
+
+// eliminate replaces repeated pure sub-expressions by shared nodes,
+// result of shared node is cached during one execution
+func (p *program) eliminate() {
+	p.root = p.dedupe(p.root, map[string]*node{})
+}
+
+func (p *program) dedupe(n *node, seen map[string]*node) *node {
+	if n.Type == operatorNode || n.Type == functionNode || n.Type == negNode {
+		if p.shareable(n) {
+			key := n.format(p.operators)
+			if shared, ok := seen[key]; ok {
+				if shared.Cache == 0 {
+					p.stats.Shared++
+					shared.Cache = p.stats.Shared
+				}
+				p.stats.Deduplicated++
+				return shared
+			}
+			seen[key] = n
+		}
+	}
+	for i, arg := range n.Args {
+		n.Args[i] = p.dedupe(arg, seen)
+	}
+	return n
+}
+
+// shareable reports whether node depends only on free variables, operators and
+// pure deterministic functions not shadowed by lambdas, so it has same value
+// everywhere in one execution
+func (p *program) shareable(n *node) bool {
+	switch n.Type {
+	case literalNode:
+		return true
+	case variableNode:
+		return n.Slot >= 0
+	case negNode:
+	case operatorNode:
+		if _, ok := p.operators[n.SValue]; !ok {
+			return false
+		}
+	case functionNode:
+		fn, ok := lookupFunction(p.functions, n.SValue, len(n.Args))
+		if !ok || !fn.foldable() || fn.Places != len(n.Args) || p.shadowed[n.SValue] {
+			return false
+		}
+	default:
+		return false
+	}
+	for _, arg := range n.Args {
+		if !p.shareable(arg) {
+			return false
+		}
+	}
+	return true
+}
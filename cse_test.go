@@ -0,0 +1,78 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEliminate(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	calls := 0
+	c.AddFunction(NewPureFunction("sqrt", func(args ...float64) (float64, error) {
+		calls++
+		return math.Sqrt(args[0]), nil
+	}, 1))
+	c.AddFunction(NewFunction("rand", func(args ...float64) (float64, error) { return 1, nil }, 0))
+	if err := c.Prepare("price*qty*(1-discount) + sqrt(price*qty*(1-discount)) * sqrt(price*qty*(1-discount)) + rand() + rand()"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := c.Stats(); stats.Deduplicated != 2 || stats.Shared != 2 {
+		t.Errorf("Expected 2 deduplicated and 2 shared nodes, actual %+v", stats)
+	}
+	vars := map[string]float64{"price": 10, "qty": 5, "discount": 0.2}
+	actual, err := c.Execute(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != 82 || calls != 1 {
+		t.Errorf("Expected 82 with 1 call, actual %f with %d calls", actual, calls)
+	}
+	values := map[string]interface{}{"price": 10, "qty": 5, "discount": 0.2}
+	for i := 0; i < 2; i++ {
+		res, err := c.Evaluate(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != Number(82) {
+			t.Errorf("Expected 82, actual %v", res)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("Expected 1 call per execution, actual %d calls", calls)
+	}
+
+	if err := c.Prepare("let x = 2; x * price + (x * price) * x"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := c.Stats(); stats.Deduplicated != 0 {
+		t.Errorf("Expected no deduplicated nodes with bound variables, actual %+v", stats)
+	}
+
+	if err := c.Prepare("apply = sqrt => sqrt(x); sqrt(x) + apply(y => y * 10)"); err != nil {
+		t.Fatal(err)
+	}
+	if actual, err := c.Execute(map[string]float64{"x": 4}); err != nil || actual != 42 {
+		t.Errorf("Expected 42 with shadowed function not shared, actual %f, %v", actual, err)
+	}
+}
@@ -20,15 +20,53 @@
 
 package executor
 
-import "math"
+import (
+	"math"
+	"sort"
+)
 
 // MathOperators is default set for math expressions
 var MathOperators = []*Operator{
-	{Op: "+", Assoc: LeftAssoc, Priority: 10, Fn: func(a float64, b float64) (float64, error) { return a + b, nil }},
-	{Op: "-", Assoc: LeftAssoc, Priority: 10, Fn: func(a float64, b float64) (float64, error) { return a - b, nil }},
-	{Op: "*", Assoc: LeftAssoc, Priority: 20, Fn: func(a float64, b float64) (float64, error) { return a * b, nil }},
-	{Op: "/", Assoc: LeftAssoc, Priority: 20, Fn: func(a float64, b float64) (float64, error) { return a / b, nil }},
-	{Op: "^", Assoc: RightAssoc, Priority: 30, Fn: func(a, b float64) (float64, error) { return math.Pow(a, b), nil }},
+	{Op: "+", Assoc: LeftAssoc, Priority: 10, Fn: func(a float64, b float64) (float64, error) { return a + b, nil },
+		Partials: func(a, b float64) (float64, float64) { return 1, 1 }},
+	{Op: "-", Assoc: LeftAssoc, Priority: 10, Fn: func(a float64, b float64) (float64, error) { return a - b, nil },
+		Partials: func(a, b float64) (float64, float64) { return 1, -1 }},
+	{Op: "*", Assoc: LeftAssoc, Priority: 20, Fn: func(a float64, b float64) (float64, error) { return a * b, nil },
+		Partials: func(a, b float64) (float64, float64) { return b, a }},
+	{Op: "/", Assoc: LeftAssoc, Priority: 20, Fn: func(a float64, b float64) (float64, error) { return a / b, nil },
+		Partials: func(a, b float64) (float64, float64) { return 1 / b, -a / (b * b) }},
+	{Op: "^", Assoc: RightAssoc, Priority: 30, Fn: func(a, b float64) (float64, error) { return math.Pow(a, b), nil },
+		Partials: func(a, b float64) (float64, float64) { return b * math.Pow(a, b-1), math.Pow(a, b) * math.Log(a) }},
+}
+
+// MathFunctions is default set of math functions with derivatives
+var MathFunctions = []*Function{
+	mathFunction("sin", math.Sin, "cos(a)", math.Cos),
+	mathFunction("cos", math.Cos, "-sin(a)", func(a float64) float64 { return -math.Sin(a) }),
+	mathFunction("tan", math.Tan, "1 / cos(a) ^ 2", func(a float64) float64 { return 1 / (math.Cos(a) * math.Cos(a)) }),
+	mathFunction("asin", math.Asin, "1 / sqrt(1 - a ^ 2)", func(a float64) float64 { return 1 / math.Sqrt(1-a*a) }),
+	mathFunction("acos", math.Acos, "-1 / sqrt(1 - a ^ 2)", func(a float64) float64 { return -1 / math.Sqrt(1-a*a) }),
+	mathFunction("atan", math.Atan, "1 / (1 + a ^ 2)", func(a float64) float64 { return 1 / (1 + a*a) }),
+	mathFunction("sinh", math.Sinh, "cosh(a)", math.Cosh),
+	mathFunction("cosh", math.Cosh, "sinh(a)", math.Sinh),
+	mathFunction("tanh", math.Tanh, "1 - tanh(a) ^ 2", func(a float64) float64 { return 1 - math.Tanh(a)*math.Tanh(a) }),
+	mathFunction("exp", math.Exp, "exp(a)", math.Exp),
+	mathFunction("log", math.Log, "1 / a", func(a float64) float64 { return 1 / a }),
+	mathFunction("sqrt", math.Sqrt, "1 / (2 * sqrt(a))", func(a float64) float64 { return 1 / (2 * math.Sqrt(a)) }),
+	mathFunction("abs", math.Abs, "a / abs(a)", func(a float64) float64 { return a / math.Abs(a) }),
+}
+
+// mathFunction returns pure function of one argument with its derivative as expression and as Go function
+func mathFunction(name string, fn func(float64) float64, derivative string, dfn func(float64) float64) *Function {
+	res := NewPureFunction(name, func(args ...float64) (float64, error) { return fn(args[0]), nil }, 1)
+	res.Derivatives = []string{derivative}
+	res.Gradient = func(args ...float64) ([]float64, error) { return []float64{dfn(args[0])}, nil }
+	return res
+}
+
+// zeroPartials returns zero partial derivatives of piecewise constant operators like comparisons
+func zeroPartials(a, b float64) (float64, float64) {
+	return 0, 0
 }
 
 // LogicOperators is default set for logic expressions
@@ -38,35 +76,177 @@ var LogicOperators = []*Operator{
 			return 1, nil
 		}
 		return 0, nil
-	}},
+	}, Partials: zeroPartials},
 	{Op: "!=", Assoc: LeftAssoc, Priority: 0, Fn: func(a float64, b float64) (float64, error) {
 		if a != b {
 			return 1, nil
 		}
 		return 0, nil
-	}},
+	}, Partials: zeroPartials},
 	{Op: ">", Assoc: LeftAssoc, Priority: 0, Fn: func(a float64, b float64) (float64, error) {
 		if a > b {
 			return 1, nil
 		}
 		return 0, nil
-	}},
+	}, Partials: zeroPartials},
 	{Op: "<", Assoc: LeftAssoc, Priority: 0, Fn: func(a float64, b float64) (float64, error) {
 		if a < b {
 			return 1, nil
 		}
 		return 0, nil
-	}},
+	}, Partials: zeroPartials},
 	{Op: ">=", Assoc: LeftAssoc, Priority: 0, Fn: func(a float64, b float64) (float64, error) {
 		if a >= b {
 			return 1, nil
 		}
 		return 0, nil
-	}},
+	}, Partials: zeroPartials},
 	{Op: "<=", Assoc: LeftAssoc, Priority: 0, Fn: func(a float64, b float64) (float64, error) {
 		if a <= b {
 			return 1, nil
 		}
 		return 0, nil
-	}},
+	}, Partials: zeroPartials},
+}
+
+// ListFunctions is default set of functions for lists and lambdas
+var ListFunctions = []*Function{
+	NewValueFunction("len", func(args ...Value) (Value, error) {
+		list, err := asList(args[0])
+		return Number(len(list)), err
+	}, 1),
+	NewValueFunction("sum", func(args ...Value) (Value, error) {
+		floats, err := listFloats(args[0])
+		var sum float64
+		for _, f := range floats {
+			sum += f
+		}
+		return Number(sum), err
+	}, 1),
+	NewValueFunction("sort", func(args ...Value) (Value, error) {
+		floats, err := listFloats(args[0])
+		if err != nil {
+			return nil, err
+		}
+		sort.Float64s(floats)
+		return ValueOf(floats)
+	}, 1),
+	NewValueFunction("map", func(args ...Value) (Value, error) {
+		list, fn, err := listAndLambda(args[0], args[1], 1)
+		if err != nil {
+			return nil, err
+		}
+		res := make(List, len(list))
+		for i, item := range list {
+			if res[i], err = fn.Call(item); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}, 2),
+	NewValueFunction("filter", func(args ...Value) (Value, error) {
+		list, fn, err := listAndLambda(args[0], args[1], 1)
+		if err != nil {
+			return nil, err
+		}
+		res := List{}
+		for _, item := range list {
+			ok, err := callPredicate(fn, item)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				res = append(res, item)
+			}
+		}
+		return res, nil
+	}, 2),
+	NewValueFunction("reduce", func(args ...Value) (Value, error) {
+		list, fn, err := listAndLambda(args[0], args[2], 2)
+		if err != nil {
+			return nil, err
+		}
+		acc := args[1]
+		for _, item := range list {
+			if acc, err = fn.Call(acc, item); err != nil {
+				return nil, err
+			}
+		}
+		return acc, nil
+	}, 3),
+	NewValueFunction("any", func(args ...Value) (Value, error) {
+		list, fn, err := listAndLambda(args[0], args[1], 1)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list {
+			ok, err := callPredicate(fn, item)
+			if err != nil || ok {
+				return Number(1), err
+			}
+		}
+		return Number(0), nil
+	}, 2),
+	NewValueFunction("all", func(args ...Value) (Value, error) {
+		list, fn, err := listAndLambda(args[0], args[1], 1)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list {
+			ok, err := callPredicate(fn, item)
+			if err != nil || !ok {
+				return Number(0), err
+			}
+		}
+		return Number(1), nil
+	}, 2),
+}
+
+func listFloats(v Value) ([]float64, error) {
+	list, err := asList(v)
+	if err != nil {
+		return nil, err
+	}
+	floats := make([]float64, len(list))
+	for i, item := range list {
+		if floats[i], err = asNumber(item); err != nil {
+			return nil, err
+		}
+	}
+	return floats, nil
+}
+
+func listAndLambda(list Value, fn Value, places int) (List, *Lambda, error) {
+	l, err := asList(list)
+	if err != nil {
+		return nil, nil, err
+	}
+	lambda, err := asLambda(fn, places)
+	return l, lambda, err
+}
+
+func callPredicate(fn *Lambda, item Value) (bool, error) {
+	res, err := fn.Call(item)
+	if err != nil {
+		return false, err
+	}
+	f, err := asNumber(res)
+	return f != 0, err
+}
+
+// LinalgOperators is default set of linear algebra operators.
+// Vectors are lists of numbers, matrices are lists of rows: `[[1, 2], [3, 4]]`.
+var LinalgOperators = []*Operator{
+	NewValueOperator("@", 20, LeftAssoc, matmul),
+}
+
+// LinalgFunctions is default set of linear algebra functions
+var LinalgFunctions = []*Function{
+	NewValueFunction("dot", func(args ...Value) (Value, error) { return dot(args[0], args[1]) }, 2),
+	NewValueFunction("cross", func(args ...Value) (Value, error) { return cross(args[0], args[1]) }, 2),
+	NewValueFunction("norm", func(args ...Value) (Value, error) { return norm(args[0]) }, 1),
+	NewValueFunction("transpose", func(args ...Value) (Value, error) { return transpose(args[0]) }, 1),
+	NewValueFunction("det", func(args ...Value) (Value, error) { return det(args[0]) }, 1),
+	NewValueFunction("inv", func(args ...Value) (Value, error) { return inv(args[0]) }, 1),
+	NewValueFunction("solve", func(args ...Value) (Value, error) { return solve(args[0], args[1]) }, 2),
 }
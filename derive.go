@@ -0,0 +1,236 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import "fmt"
+
+// Derive returns simplified expression of derivative of expression by variable.
+// Operators `+`, `-`, `*`, `/`, `^`, conditions, functions with Derivatives and functions
+// created by Define are supported.
+func (c *Calc) Derive(expression, variable string) (string, error) {
+	t := c.newTokenizer(expression, c.limits)
+	if err := t.tokenize(); err != nil {
+		return "", err
+	}
+	root, err := c.newParser(expression, t.tkns, c.limits).parseScript()
+	if err != nil {
+		return "", err
+	}
+	if err := c.limits.check(root, c.functions); err != nil {
+		return "", err
+	}
+	d := &deriver{c: c, variable: variable}
+	res, err := d.derive(root)
+	if err != nil {
+		return "", err
+	}
+	p := &program{functions: c.functions, operators: c.operators}
+	return p.optimize(res, true).format(c.operators), nil
+}
+
+// deriver builds derivative of expression tree
+type deriver struct {
+	c        *Calc
+	variable string
+}
+
+func (d *deriver) derive(n *node) (*node, error) {
+	if !depends(n, d.variable) {
+		return number(0), nil
+	}
+	switch n.Type {
+	case variableNode:
+		return number(1), nil
+	case negNode:
+		du, err := d.derive(n.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return newNode(negNode, "", 0, du), nil
+	case operatorNode:
+		return d.deriveOperator(n)
+	case functionNode:
+		return d.deriveFunction(n)
+	case conditionNode:
+		a, err := d.derive(n.Args[1])
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.derive(n.Args[2])
+		if err != nil {
+			return nil, err
+		}
+		return newNode(conditionNode, "", 0, n.Args[0], a, b), nil
+	}
+	return nil, fmt.Errorf("can't derive %s", n.format(d.c.operators))
+}
+
+func (d *deriver) deriveOperator(n *node) (*node, error) {
+	u, v := n.Args[0], n.Args[1]
+	du, err := d.derive(u)
+	if err != nil {
+		return nil, err
+	}
+	dv, err := d.derive(v)
+	if err != nil {
+		return nil, err
+	}
+	switch n.SValue {
+	case "+", "-":
+		return operator(n.SValue, du, dv), nil
+	case "*":
+		return operator("+", operator("*", du, v), operator("*", u, dv)), nil
+	case "/":
+		return operator("/",
+			operator("-", operator("*", du, v), operator("*", u, dv)),
+			operator("^", v, number(2))), nil
+	case "^":
+		switch {
+		case !depends(v, d.variable):
+			return operator("*", operator("*", v, operator("^", u, operator("-", v, number(1)))), du), nil
+		case !depends(u, d.variable):
+			return operator("*", operator("*", n, call("log", u)), dv), nil
+		}
+		return operator("*", n, operator("+",
+			operator("*", dv, call("log", u)),
+			operator("/", operator("*", v, du), u))), nil
+	}
+	return nil, fmt.Errorf("can't derive operator '%s'", n.SValue)
+}
+
+// calls reports whether n calls function name directly or through bodies of defined functions
+func calls(n *node, name string, functions map[string]*Function, visited map[*Function]bool) bool {
+	if n.Type == functionNode {
+		if n.SValue == name {
+			return true
+		}
+		if fn, ok := lookupFunction(functions, n.SValue, len(n.Args)); ok && fn.body != nil && !visited[fn] {
+			visited[fn] = true
+			if calls(fn.body, name, functions, visited) {
+				return true
+			}
+		}
+	}
+	for _, arg := range n.Args {
+		if calls(arg, name, functions, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// deriveFunction applies chain rule to call of function
+func (d *deriver) deriveFunction(n *node) (*node, error) {
+	fn, ok := lookupFunction(d.c.functions, n.SValue, len(n.Args))
+	if !ok {
+		return nil, &UnknownFunctionError{Name: n.SValue}
+	}
+	if len(n.Args) != fn.Places {
+		return nil, &ArityError{Func: fn.Name, Want: fn.Places, Got: len(n.Args)}
+	}
+	if fn.body != nil {
+		if calls(fn.body, fn.Name, d.c.functions, map[*Function]bool{}) {
+			return nil, fmt.Errorf("can't derive recursive function '%s'", fn.Name)
+		}
+		args := make(map[string]*node, len(fn.params))
+		for i, name := range fn.params {
+			args[name] = n.Args[i]
+		}
+		return d.derive(substitute(fn.body, args))
+	}
+	if len(fn.Derivatives) != fn.Places {
+		return nil, fmt.Errorf("function '%s' has no derivative", fn.Name)
+	}
+	args := make(map[string]*node, len(n.Args))
+	for i, arg := range n.Args {
+		args[derivativeParam(i)] = arg
+	}
+	var res *node
+	for i, arg := range n.Args {
+		if !depends(arg, d.variable) {
+			continue
+		}
+		darg, err := d.derive(arg)
+		if err != nil {
+			return nil, err
+		}
+		t := newTokenizer(fn.Derivatives[i], d.c.operators)
+		if err := t.tokenize(); err != nil {
+			return nil, fmt.Errorf("derivative of function '%s': %w", fn.Name, err)
+		}
+		partial, err := newParser(t.tkns, d.c.operators).parseExpr()
+		if err != nil {
+			return nil, fmt.Errorf("derivative of function '%s': %w", fn.Name, err)
+		}
+		term := operator("*", substitute(partial, args), darg)
+		if res == nil {
+			res = term
+		} else {
+			res = operator("+", res, term)
+		}
+	}
+	return res, nil
+}
+
+// derivativeParam returns name of i-th argument in Derivatives of function: a, b, c...
+func derivativeParam(i int) string {
+	return string(rune('a' + i))
+}
+
+// depends reports whether node depends on variable
+func depends(n *node, variable string) bool {
+	if n.Type == variableNode && n.SValue == variable {
+		return true
+	}
+	for _, arg := range n.Args {
+		if depends(arg, variable) {
+			return true
+		}
+	}
+	return false
+}
+
+// substitute returns copy of node with variables replaced by nodes
+func substitute(n *node, vars map[string]*node) *node {
+	if n.Type == variableNode {
+		if v, ok := vars[n.SValue]; ok {
+			return v
+		}
+	}
+	res := *n
+	res.Args = make([]*node, len(n.Args))
+	for i, arg := range n.Args {
+		res.Args[i] = substitute(arg, vars)
+	}
+	return &res
+}
+
+func number(v float64) *node {
+	return newNode(literalNode, "", v)
+}
+
+func operator(op string, a, b *node) *node {
+	return newNode(operatorNode, op, 0, a, b)
+}
+
+func call(name string, args ...*node) *node {
+	return newNode(functionNode, name, 0, args...)
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDerive(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddOperators(LogicOperators)
+	c.AddFunctions(MathFunctions)
+	if err := c.Define("sq(u) = u * u"); err != nil {
+		t.Fatal(err)
+	}
+	hyp := NewFunction("hyp", func(args ...float64) (float64, error) { return math.Hypot(args[0], args[1]), nil }, 2)
+	hyp.Derivatives = []string{"a / hyp(a, b)", "b / hyp(a, b)"}
+	c.AddFunction(hyp)
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"x^2", "2 * x"},
+		{"3*x^3 + 2*x - 7", "9 * x ^ 2 + 2"},
+		{"y*x + y", "y"},
+		{"cos(x)", "-sin(x)"},
+		{"x^x", "x ^ x * (log(x) + 1)"},
+		{"sq(sin(x))", "cos(x) * sin(x) + sin(x) * cos(x)"},
+		{"sq(sq(x))", "(x + x) * sq(x) + sq(x) * (x + x)"},
+		{"hyp(x, 3)", "x / hyp(x, 3)"},
+		{"y - (y - 1) * x", "-(y - 1)"},
+		{"y - x * y", "-y"},
+		{"1 / (2 / x)", "2 / x ^ 2 / (2 / x) ^ 2"},
+	}
+	for _, test := range tests {
+		actual, err := c.Derive(test.expression, "x")
+		if err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		if actual != test.expected {
+			t.Errorf("%s: expected %s, actual %s", test.expression, test.expected, actual)
+		}
+	}
+
+	for _, expression := range []string{"exp(2*x)/x", "log(x^2+1) * tan(x)", "2^x - sqrt(x)", "x > 1 ? x^3 : -x", "atan(x) + hyp(x, x)"} {
+		derivative, err := c.Derive(expression, "x")
+		if err != nil {
+			t.Errorf("%s: %v", expression, err)
+			continue
+		}
+		for _, x := range []float64{0.5, 1.5, 2} {
+			numeric := (execute(t, c, expression, x+1e-6) - execute(t, c, expression, x-1e-6)) / 2e-6
+			if actual := execute(t, c, derivative, x); math.Abs(actual-numeric) > 1e-4 {
+				t.Errorf("%s at %f: expected %f, actual %f (%s)", expression, x, numeric, actual, derivative)
+			}
+		}
+	}
+
+	if err := c.Define("fact(n) = n <= 1 ? 1 : n * fact(n - 1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Define("even(n) = n <= 0 ? 1 : odd(n - 1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Define("odd(n) = n <= 0 ? 0 : even(n - 1)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, expression := range []string{"x == 1", "let y = x; y", "unknown(x)", "fact(x)", "odd(x)"} {
+		if _, err := c.Derive(expression, "x"); err == nil {
+			t.Errorf("%s: expected error", expression)
+		}
+	}
+
+	c.SetLimits(Limits{DeniedFunctions: []string{"sin"}})
+	if _, err := c.Derive("sin(x)", "x"); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("Expected %v, got %v", ErrNotAllowed, err)
+	}
+}
+
+func execute(t *testing.T, c *Calc, expression string, x float64) float64 {
+	if err := c.Prepare(expression); err != nil {
+		t.Fatalf("%s: %v", expression, err)
+	}
+	res, err := c.Execute(map[string]float64{"x": x})
+	if err != nil {
+		t.Fatalf("%s: %v", expression, err)
+	}
+	return res
+}
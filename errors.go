@@ -0,0 +1,137 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// UnknownVariableError is returned when expression uses variable that is not provided
+type UnknownVariableError struct {
+	Name string
+}
+
+func (e *UnknownVariableError) Error() string {
+	return fmt.Sprintf("unknown variable '%s'", e.Name)
+}
+
+// UnknownFunctionError is returned when expression calls function that is not added
+type UnknownFunctionError struct {
+	Name string
+}
+
+func (e *UnknownFunctionError) Error() string {
+	return fmt.Sprintf("unknown function '%s'", e.Name)
+}
+
+// UnknownOperatorError is returned when expression uses operator that is not added
+type UnknownOperatorError struct {
+	Op string
+}
+
+func (e *UnknownOperatorError) Error() string {
+	return fmt.Sprintf("unknown operator '%s'", e.Op)
+}
+
+// ArityError is returned when function or lambda is called with wrong count of arguments.
+// Func is empty for lambdas.
+type ArityError struct {
+	Func string
+	Want int
+	Got  int
+}
+
+func (e *ArityError) Error() string {
+	if e.Func == "" {
+		return fmt.Sprintf("lambda expects %d args, got %d", e.Want, e.Got)
+	}
+	return fmt.Sprintf("function '%s' expects %d args, got %d", e.Func, e.Want, e.Got)
+}
+
+// NotPreparedError is returned when expression is executed before Prepare
+type NotPreparedError struct{}
+
+func (e *NotPreparedError) Error() string {
+	return "must prepare expression"
+}
+
+// FunctionError is error returned by Go function or error of its arguments.
+// Pos is byte offset of the call in expression.
+type FunctionError struct {
+	Func string
+	Pos  int
+	Err  error
+}
+
+func (e *FunctionError) Error() string {
+	return fmt.Sprintf("function '%s': %v", e.Func, e.Err)
+}
+
+// Unwrap returns error of function
+func (e *FunctionError) Unwrap() error {
+	return e.Err
+}
+
+// EvalError is runtime error annotated with failing sub-expression.
+// Error of call is wrapped by EvalError of the call even if it is EvalError of function body,
+// so nested EvalErrors are chain of calls from expression to failing node.
+type EvalError struct {
+	Pos  int    // byte offset of sub-expression in expression or definition of function
+	Text string // source text of sub-expression
+	Err  error
+}
+
+func newEvalError(n *node, err error) *EvalError {
+	return &EvalError{Pos: n.Pos, Text: n.Text, Err: err}
+}
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("%s at %d: %v", e.Text, e.Pos, e.Err)
+}
+
+// Unwrap returns error of sub-expression
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+// annotate wraps error of node by EvalError, unless it is already annotated by child node
+func annotate(n *node, err error) error {
+	if _, ok := err.(*EvalError); ok || aborts(err) {
+		return err
+	}
+	return newEvalError(n, err)
+}
+
+// annotateCall wraps error of call by EvalError
+func annotateCall(n *node, err error) error {
+	if err == nil || aborts(err) {
+		return err
+	}
+	return newEvalError(n, err)
+}
+
+// aborts reports whether error stops whole execution, such errors are returned as is
+func aborts(err error) bool {
+	return errors.Is(err, ErrRecursionLimit) || errors.Is(err, ErrStepLimit) || errors.Is(err, ErrCallLimit) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,376 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// state is shared by all scopes of one evaluation
+type state struct {
+	ctx   context.Context
+	depth int
+	steps int
+	calls int
+	slots []float64
+	cache []Value // results of shared nodes
+}
+
+// step counts evaluation step and checks limits and cancellation
+func (p *program) step(st *state) error {
+	st.steps++
+	if p.stepLimit > 0 && st.steps > p.stepLimit {
+		return ErrStepLimit
+	}
+	if st.ctx != nil {
+		select {
+		case <-st.ctx.Done():
+			return st.ctx.Err()
+		default:
+		}
+	}
+	return nil
+}
+
+// enter counts function call and checks limit of calls
+func (p *program) enter(st *state) error {
+	st.calls++
+	if p.callLimit > 0 && st.calls > p.callLimit {
+		return ErrCallLimit
+	}
+	return nil
+}
+
+// env holds variables visible during evaluation
+type env struct {
+	locals   map[string]Value
+	parent   *env
+	vars     Resolver
+	assigned map[string]Value
+	st       *state
+}
+
+func (e *env) lookup(name string) (Value, bool, error) {
+	path := strings.Split(name, ".")
+	for ; e != nil; e = e.parent {
+		if v, ok := e.locals[name]; ok {
+			return v, true, nil
+		}
+		if v, ok := e.locals[path[0]]; ok && len(path) > 1 {
+			res, err := member(v, path[1:], name)
+			return res, true, err
+		}
+		if e.vars == nil {
+			continue
+		}
+		v, ok, err := e.vars.Resolve(name)
+		if err != nil {
+			return nil, true, fmt.Errorf("variable '%s': %w", name, err)
+		}
+		if ok {
+			return v, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// local looks name up in local scopes only, so lambdas can't come from Resolver
+func (e *env) local(name string) (Value, bool) {
+	for ; e != nil; e = e.parent {
+		if v, ok := e.locals[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// eval evaluates node, its own errors are annotated by EvalError
+func (p *program) eval(n *node, e *env) (Value, error) {
+	if n.Cache > 0 && e.st.cache != nil && e.st.cache[n.Cache-1] != nil {
+		return e.st.cache[n.Cache-1], nil
+	}
+	res, err := p.evalNode(n, e)
+	if err != nil {
+		return nil, annotate(n, err)
+	}
+	if n.Cache > 0 && e.st.cache != nil {
+		e.st.cache[n.Cache-1] = res
+	}
+	return res, nil
+}
+
+func (p *program) evalNode(n *node, e *env) (Value, error) {
+	if err := p.step(e.st); err != nil {
+		return nil, err
+	}
+	switch n.Type {
+	case literalNode:
+		return Number(n.FValue), nil
+	case variableNode:
+		if n.Slot >= 0 && e.st.slots != nil {
+			return Number(e.st.slots[n.Slot]), nil
+		}
+		res, exists, err := e.lookup(n.SValue)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, &UnknownVariableError{Name: n.SValue}
+		}
+		return res, nil
+	case negNode:
+		res, err := p.eval(n.Args[0], e)
+		if err != nil {
+			return nil, err
+		}
+		return elementwise(Number(-1), res, func(a, b float64) (float64, error) { return a * b, nil })
+	case operatorNode:
+		op, ok := p.operators[n.SValue]
+		if !ok {
+			return nil, &UnknownOperatorError{Op: n.SValue}
+		}
+		a, err := p.eval(n.Args[0], e)
+		if err != nil {
+			return nil, err
+		}
+		b, err := p.eval(n.Args[1], e)
+		if err != nil {
+			return nil, err
+		}
+		var res Value
+		if op.ValueFn != nil {
+			res, err = op.ValueFn(a, b)
+		} else {
+			res, err = elementwise(a, b, op.Fn)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operator '%s': %w", n.SValue, err)
+		}
+		return res, nil
+	case functionNode:
+		if fn, ok := lookupFunction(p.functions, n.SValue, len(n.Args)); ok && fn.Binds {
+			res, err := p.callBinding(fn, n, e)
+			return res, annotateCall(n, err)
+		}
+		args := make([]Value, len(n.Args))
+		for i, arg := range n.Args {
+			res, err := p.eval(arg, e)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = res
+		}
+		if v, ok := e.local(n.SValue); ok {
+			if lambda, ok := v.(*Lambda); ok {
+				res, err := lambda.Call(args...)
+				return res, annotateCall(n, err)
+			}
+		}
+		fn, exists := lookupFunction(p.functions, n.SValue, len(n.Args))
+		if !exists {
+			return nil, &UnknownFunctionError{Name: n.SValue}
+		}
+		res, err := p.callFunction(fn, args, e.st, n.Pos)
+		return res, annotateCall(n, err)
+	case conditionNode:
+		cond, err := p.evalNumber(n.Args[0], e)
+		if err != nil {
+			return nil, err
+		}
+		if cond != 0 {
+			return p.eval(n.Args[1], e)
+		}
+		return p.eval(n.Args[2], e)
+	case assignNode, letNode:
+		res, err := p.eval(n.Args[0], e)
+		if err != nil {
+			return nil, err
+		}
+		e.locals[n.SValue] = res
+		if n.Type == assignNode && e.assigned != nil {
+			e.assigned[n.SValue] = res
+		}
+		return res, nil
+	case scriptNode:
+		var res Value
+		for _, stmt := range n.Args {
+			var err error
+			if res, err = p.eval(stmt, e); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case listNode:
+		list := make(List, len(n.Args))
+		for i, item := range n.Args {
+			res, err := p.eval(item, e)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = res
+		}
+		return list, nil
+	case indexNode:
+		v, err := p.eval(n.Args[0], e)
+		if err != nil {
+			return nil, err
+		}
+		list, err := asList(v)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := p.eval(n.Args[1], e)
+		if err != nil {
+			return nil, err
+		}
+		i, err := asIndex(idx, len(list))
+		if err != nil {
+			return nil, err
+		}
+		return list[i], nil
+	case lambdaNode:
+		return &Lambda{params: n.Params, body: n.Args[0], env: e, prog: p}, nil
+	case memberNode:
+		v, err := p.eval(n.Args[0], e)
+		if err != nil {
+			return nil, err
+		}
+		return member(v, strings.Split(n.SValue, "."), n.format(p.operators))
+	}
+	return nil, fmt.Errorf("unknown node %d, %s, %f", n.Type, n.SValue, n.FValue)
+}
+
+// member returns value of nested maps by keys, path is used for errors
+func member(v Value, keys []string, path string) (Value, error) {
+	for _, key := range keys {
+		m, ok := v.(Map)
+		if !ok {
+			return nil, fmt.Errorf("expected map at '%s', got %s", path, typeName(v))
+		}
+		if v, ok = m[key]; !ok {
+			return nil, fmt.Errorf("missing key '%s' at '%s'", key, path)
+		}
+	}
+	return v, nil
+}
+
+func (p *program) evalNumber(n *node, e *env) (float64, error) {
+	res, err := p.eval(n, e)
+	if err != nil {
+		return 0, err
+	}
+	return asNumber(res)
+}
+
+// callFunction calls function at position pos of expression.
+// Errors of Go functions and their arguments are wrapped by FunctionError.
+func (p *program) callFunction(fn *Function, args []Value, st *state, pos int) (Value, error) {
+	if len(args) != fn.Places {
+		return nil, &ArityError{Func: fn.Name, Want: fn.Places, Got: len(args)}
+	}
+	if err := p.enter(st); err != nil {
+		return nil, err
+	}
+	if fn.body != nil {
+		return p.call(fn, args, st)
+	}
+	if fn.ValueFn != nil {
+		res, err := fn.ValueFn(args...)
+		if err != nil {
+			return nil, &FunctionError{Func: fn.Name, Pos: pos, Err: err}
+		}
+		return res, nil
+	}
+	floats := make([]float64, len(args))
+	for i, arg := range args {
+		f, err := asNumber(arg)
+		if err != nil {
+			return nil, &FunctionError{Func: fn.Name, Pos: pos, Err: err}
+		}
+		floats[i] = f
+	}
+	var res float64
+	var err error
+	if fn.CtxFn != nil && st.ctx != nil {
+		res, err = fn.CtxFn(st.ctx, floats...)
+	} else {
+		res, err = fn.Fn(floats...)
+	}
+	if err != nil {
+		return nil, &FunctionError{Func: fn.Name, Pos: pos, Err: err}
+	}
+	return Number(res), nil
+}
+
+// callBinding calls function that binds variable named by second argument in first argument
+func (p *program) callBinding(fn *Function, n *node, e *env) (Value, error) {
+	if len(n.Args) != fn.Places {
+		return nil, &ArityError{Func: fn.Name, Want: fn.Places, Got: len(n.Args)}
+	}
+	name, ok := boundName(n)
+	if !ok {
+		return nil, &FunctionError{Func: fn.Name, Pos: n.Pos, Err: fmt.Errorf("expected name of variable, got %s", n.Args[1].format(p.operators))}
+	}
+	args := make([]Value, len(n.Args)-1)
+	args[0] = &Lambda{params: []string{name}, body: n.Args[0], env: e, prog: p}
+	for i, arg := range n.Args[2:] {
+		res, err := p.eval(arg, e)
+		if err != nil {
+			return nil, err
+		}
+		args[i+1] = res
+	}
+	if err := p.enter(e.st); err != nil {
+		return nil, err
+	}
+	res, err := fn.ValueFn(args...)
+	if err != nil {
+		return nil, &FunctionError{Func: fn.Name, Pos: n.Pos, Err: err}
+	}
+	return res, nil
+}
+
+// boundName returns name of variable bound by call of binding function
+func boundName(n *node) (string, bool) {
+	if len(n.Args) < 2 || n.Args[1].Type != variableNode || strings.Contains(n.Args[1].SValue, ".") {
+		return "", false
+	}
+	return n.Args[1].SValue, true
+}
+
+// call executes defined function. Its body sees only parameters.
+func (p *program) call(fn *Function, args []Value, st *state) (Value, error) {
+	if st.depth >= p.recursionLimit {
+		return nil, ErrRecursionLimit
+	}
+	if len(args) != len(fn.params) {
+		return nil, &ArityError{Func: fn.Name, Want: len(fn.params), Got: len(args)}
+	}
+	locals := make(map[string]Value, len(args))
+	for i, name := range fn.params {
+		locals[name] = args[i]
+	}
+	st.depth++
+	defer func() { st.depth-- }()
+	return p.eval(fn.body, &env{locals: locals, st: st})
+}
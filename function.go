@@ -20,14 +20,153 @@
 
 package executor
 
-// Function represents custom functions
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// Function represents custom functions.
+// Fn accepts only numbers, ValueFn (if set) accepts any values like lists and lambdas.
+// CtxFn (if set) receives context of ExecuteContext.
+// Pure function has no side effects, Deterministic function returns same result for same arguments.
+// Calls of function that is both pure and deterministic can be computed once: with constant
+// arguments by Prepare, with any arguments by cache enabled with Memoize.
+// Derivatives are expressions of partial derivatives by each argument used by Derive,
+// arguments are named `a`, `b`, `c` and so on, for example `cos(a)` for `sin`.
+// Gradient (if set) returns partial derivatives of Fn by each argument for ExecuteGradient.
+// Binds function receives its first argument unevaluated as lambda of variable named by second
+// argument, for example `sum(i^2, i, 1, 10)` calls ValueFn with `i => i^2`, 1 and 10.
 type Function struct {
-	Name   string
-	Fn     func(args ...float64) (float64, error)
-	Places int
+	Name          string
+	Fn            func(args ...float64) (float64, error)
+	ValueFn       func(args ...Value) (Value, error)
+	CtxFn         func(ctx context.Context, args ...float64) (float64, error)
+	Places        int
+	Pure          bool
+	Deterministic bool
+	Derivatives   []string
+	Gradient      func(args ...float64) ([]float64, error)
+	Binds         bool
+	params        []string
+	body          *node
+	memo          *memo
 }
 
 // NewFunction creates Function instance
 func NewFunction(name string, fn func(args ...float64) (float64, error), places int) *Function {
 	return &Function{Name: name, Fn: fn, Places: places}
 }
+
+// NewPureFunction creates Function instance marked as pure and deterministic
+func NewPureFunction(name string, fn func(args ...float64) (float64, error), places int) *Function {
+	return &Function{Name: name, Fn: fn, Places: places, Pure: true, Deterministic: true}
+}
+
+// NewValueFunction creates Function instance that accepts any values
+func NewValueFunction(name string, fn func(args ...Value) (Value, error), places int) *Function {
+	return &Function{Name: name, ValueFn: fn, Places: places}
+}
+
+// NewBindingFunction creates Function instance that binds variable in its first argument.
+// Places counts arguments in expression including name of variable.
+func NewBindingFunction(name string, fn func(args ...Value) (Value, error), places int) *Function {
+	return &Function{Name: name, ValueFn: fn, Places: places, Binds: true}
+}
+
+// NewContextFunction creates Function instance that receives context of execution.
+// Without ExecuteContext fn receives context.Background().
+func NewContextFunction(name string, fn func(ctx context.Context, args ...float64) (float64, error), places int) *Function {
+	return &Function{
+		Name: name,
+		Fn: func(args ...float64) (float64, error) {
+			return fn(context.Background(), args...)
+		},
+		CtxFn:  fn,
+		Places: places,
+	}
+}
+
+// Memoize enables cache of last size results of function shared by all executions.
+// Cache is used only if function is pure and deterministic.
+func (f *Function) Memoize(size int) *Function {
+	f.memo = newMemo(size)
+	return f
+}
+
+// foldable reports whether calls of function with same arguments can be computed once
+func (f *Function) foldable() bool {
+	return f.Pure && f.Deterministic && f.Fn != nil && f.ValueFn == nil && f.body == nil
+}
+
+// overloadKey is key of function in map of functions when last added function of same name
+// takes other count of arguments
+func overloadKey(name string, places int) string {
+	return name + "/" + strconv.Itoa(places)
+}
+
+// lookupFunction returns function named name that takes args arguments,
+// or last added function named name if there is no such function
+func lookupFunction(functions map[string]*Function, name string, args int) (*Function, bool) {
+	fn, ok := functions[name]
+	if ok && fn.Places != args {
+		if overload, found := functions[overloadKey(name, args)]; found {
+			return overload, true
+		}
+	}
+	return fn, ok
+}
+
+// memoized returns copy of function that uses its cache
+func (f *Function) memoized() *Function {
+	if f.memo == nil || !f.foldable() {
+		return f
+	}
+	res := *f
+	fn, ctxFn := f.Fn, f.CtxFn
+	res.Fn = func(args ...float64) (float64, error) {
+		return f.memo.call(args, func() (float64, error) { return fn(args...) })
+	}
+	if ctxFn != nil {
+		res.CtxFn = func(ctx context.Context, args ...float64) (float64, error) {
+			return f.memo.call(args, func() (float64, error) { return ctxFn(ctx, args...) })
+		}
+	}
+	return &res
+}
+
+func (f *Function) hasParam(name string) bool {
+	for _, p := range f.params {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDefinition parses head of definition `name(a, b) =` and returns tokens of body
+func parseDefinition(tkns []*token) (*Function, []*token, error) {
+	if len(tkns) < 4 || tkns[0].Type != functionType || tkns[1].Type != leftParenthesisType {
+		return nil, nil, ErrInvalidDefinition
+	}
+	fn := &Function{Name: tkns[0].SValue}
+	i := 2
+	for ; i < len(tkns) && tkns[i].Type != rightParenthesisType; i++ {
+		if (i%2 == 0) != (tkns[i].Type == variableType) {
+			return nil, nil, ErrInvalidDefinition
+		}
+		if tkns[i].Type == variableType {
+			if fn.hasParam(tkns[i].SValue) {
+				return nil, nil, fmt.Errorf("duplicate parameter '%s'", tkns[i].SValue)
+			}
+			fn.params = append(fn.params, tkns[i].SValue)
+		} else if tkns[i].Type != funcSep {
+			return nil, nil, ErrInvalidDefinition
+		}
+	}
+	if i+2 >= len(tkns) || tkns[i-1].Type == funcSep || tkns[i+1].Type != operatorType || tkns[i+1].SValue != "=" {
+		return nil, nil, ErrInvalidDefinition
+	}
+	fn.Places = len(fn.params)
+	return fn, tkns[i+2:], nil
+}
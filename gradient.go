@@ -0,0 +1,220 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import "fmt"
+
+// dual is value with its partial derivatives by chosen variables, nil derivatives are zero
+type dual struct {
+	v float64
+	d []float64
+}
+
+// gradient is state of evaluation with dual numbers
+type gradient struct {
+	p     *program
+	slots []float64
+	wrt   map[string]int
+	depth int
+}
+
+// ExecuteGradient executes prepared expression and returns also its partial derivatives
+// by variables wrt in one pass. Operators must have Partials and functions must have Gradient
+// or be created by Define, if their arguments depend on wrt.
+func (c *Calc) ExecuteGradient(vars map[string]float64, wrt ...string) (float64, []float64, error) {
+	if c.program == nil {
+		return 0, nil, &NotPreparedError{}
+	}
+	slots, err := c.program.slotsOf(vars)
+	if err != nil {
+		return 0, nil, err
+	}
+	return c.program.executeGradient(slots, wrt)
+}
+
+// executeGradient executes program with dual numbers
+func (p *program) executeGradient(slots []float64, wrt []string) (float64, []float64, error) {
+	g := &gradient{p: p, slots: slots, wrt: make(map[string]int, len(wrt))}
+	for i, name := range wrt {
+		g.wrt[name] = i
+	}
+	res, err := g.eval(p.root, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	grad := make([]float64, len(wrt))
+	copy(grad, res.d)
+	return res.v, grad, nil
+}
+
+// eval evaluates node with locals of defined function
+func (g *gradient) eval(n *node, locals map[string]dual) (dual, error) {
+	res, err := g.evalNode(n, locals)
+	if err != nil {
+		return dual{}, annotate(n, err)
+	}
+	return res, nil
+}
+
+func (g *gradient) evalNode(n *node, locals map[string]dual) (dual, error) {
+	switch n.Type {
+	case literalNode:
+		return dual{v: n.FValue}, nil
+	case variableNode:
+		if locals != nil {
+			if v, ok := locals[n.SValue]; ok {
+				return v, nil
+			}
+			return dual{}, &UnknownVariableError{Name: n.SValue}
+		}
+		if n.Slot < 0 {
+			break
+		}
+		res := dual{v: g.slots[n.Slot]}
+		if i, ok := g.wrt[n.SValue]; ok {
+			res.d = make([]float64, len(g.wrt))
+			res.d[i] = 1
+		}
+		return res, nil
+	case negNode:
+		arg, err := g.eval(n.Args[0], locals)
+		if err != nil {
+			return dual{}, err
+		}
+		return g.chain(-arg.v, []dual{arg}, []float64{-1}), nil
+	case operatorNode:
+		op, ok := g.p.operators[n.SValue]
+		if !ok {
+			return dual{}, &UnknownOperatorError{Op: n.SValue}
+		}
+		if op.Fn == nil {
+			break
+		}
+		a, err := g.eval(n.Args[0], locals)
+		if err != nil {
+			return dual{}, err
+		}
+		b, err := g.eval(n.Args[1], locals)
+		if err != nil {
+			return dual{}, err
+		}
+		v, err := op.Fn(a.v, b.v)
+		if err != nil {
+			return dual{}, fmt.Errorf("operator '%s': %w", op.Op, err)
+		}
+		if a.d == nil && b.d == nil {
+			return dual{v: v}, nil
+		}
+		if op.Partials == nil {
+			return dual{}, fmt.Errorf("operator '%s' has no partial derivatives", op.Op)
+		}
+		da, db := op.Partials(a.v, b.v)
+		return g.chain(v, []dual{a, b}, []float64{da, db}), nil
+	case functionNode:
+		return g.call(n, locals)
+	case conditionNode:
+		cond, err := g.eval(n.Args[0], locals)
+		if err != nil {
+			return dual{}, err
+		}
+		if cond.v != 0 {
+			return g.eval(n.Args[1], locals)
+		}
+		return g.eval(n.Args[2], locals)
+	}
+	return dual{}, fmt.Errorf("%s is not supported in gradient mode", n.format(g.p.operators))
+}
+
+// call calls function, defined functions are evaluated with dual numbers too
+func (g *gradient) call(n *node, locals map[string]dual) (dual, error) {
+	fn, ok := lookupFunction(g.p.functions, n.SValue, len(n.Args))
+	if !ok {
+		return dual{}, &UnknownFunctionError{Name: n.SValue}
+	}
+	if fn.ValueFn != nil {
+		return dual{}, fmt.Errorf("%s is not supported in gradient mode", n.format(g.p.operators))
+	}
+	if len(n.Args) != fn.Places {
+		return dual{}, &ArityError{Func: fn.Name, Want: fn.Places, Got: len(n.Args)}
+	}
+	args := make([]dual, len(n.Args))
+	values := make([]float64, len(n.Args))
+	fixed := true
+	for i, arg := range n.Args {
+		var err error
+		if args[i], err = g.eval(arg, locals); err != nil {
+			return dual{}, err
+		}
+		values[i] = args[i].v
+		fixed = fixed && args[i].d == nil
+	}
+	if fn.body != nil {
+		if g.depth >= g.p.recursionLimit {
+			return dual{}, ErrRecursionLimit
+		}
+		params := make(map[string]dual, len(args))
+		for i, name := range fn.params {
+			params[name] = args[i]
+		}
+		g.depth++
+		defer func() { g.depth-- }()
+		res, err := g.eval(fn.body, params)
+		return res, annotateCall(n, err)
+	}
+	v, err := fn.Fn(values...)
+	if err != nil {
+		return dual{}, &FunctionError{Func: fn.Name, Pos: n.Pos, Err: err}
+	}
+	if fixed {
+		return dual{v: v}, nil
+	}
+	if fn.Gradient == nil {
+		return dual{}, fmt.Errorf("function '%s' has no gradient", fn.Name)
+	}
+	partials, err := fn.Gradient(values...)
+	if err != nil {
+		return dual{}, &FunctionError{Func: fn.Name, Pos: n.Pos, Err: err}
+	}
+	if len(partials) != len(args) {
+		return dual{}, fmt.Errorf("gradient of function '%s' has %d partials, expected %d", fn.Name, len(partials), len(args))
+	}
+	return g.chain(v, args, partials), nil
+}
+
+// chain returns v with derivatives of args multiplied by partials.
+// Args with zero derivatives are skipped, so infinite partials of constants don't produce NaN.
+func (g *gradient) chain(v float64, args []dual, partials []float64) dual {
+	res := dual{v: v}
+	for i, arg := range args {
+		if arg.d == nil {
+			continue
+		}
+		if res.d == nil {
+			res.d = make([]float64, len(g.wrt))
+		}
+		for j, d := range arg.d {
+			if d != 0 {
+				res.d[j] += partials[i] * d
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExecuteGradient(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddOperators(LogicOperators)
+	c.AddFunctions(MathFunctions)
+	if err := c.Define("sq(u) = u * u"); err != nil {
+		t.Fatal(err)
+	}
+	expression := "sq(sin(x)) * y + x ^ y / exp(y) + (x > 1 ? x * 3 : -x) + k"
+	if err := c.Prepare(expression); err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]float64{"x": 1.5, "y": 2, "k": 10}
+	value, grad, err := c.ExecuteGradient(vars, "x", "y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := c.Execute(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != expected {
+		t.Errorf("Expected %f, actual %f", expected, value)
+	}
+	for i, name := range []string{"x", "y"} {
+		shifted := map[string]float64{"x": vars["x"], "y": vars["y"], "k": vars["k"]}
+		shifted[name] += 1e-6
+		plus, _ := c.Execute(shifted)
+		shifted[name] -= 2e-6
+		minus, _ := c.Execute(shifted)
+		if numeric := (plus - minus) / 2e-6; math.Abs(grad[i]-numeric) > 1e-5 {
+			t.Errorf("d/d%s: expected %f, actual %f", name, numeric, grad[i])
+		}
+	}
+
+	if err := c.Prepare("x ^ 2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, grad, err := c.ExecuteGradient(map[string]float64{"x": -3}, "x"); err != nil || grad[0] != -6 {
+		t.Errorf("Expected -6, actual %v, %v", grad, err)
+	}
+
+	c.AddFunction(NewFunction("opaque", func(args ...float64) (float64, error) { return args[0], nil }, 1))
+	if err := c.Prepare("opaque(x) + opaque(y)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.ExecuteGradient(map[string]float64{"x": 1, "y": 2}, "x"); err == nil {
+		t.Error("Expected error for function without gradient")
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import "fmt"
+
+// DefaultMaxDepth is nesting of expressions allowed when Limits.MaxDepth is zero.
+// Parser and evaluator are recursive, so deeper expressions would overflow the stack.
+const DefaultMaxDepth = 10000
+
+// Limits restricts expressions accepted by Prepare and Define.
+// Zero or nil fields mean no limit, zero MaxDepth means DefaultMaxDepth.
+type Limits struct {
+	MaxLength  int     // length of expression in bytes
+	MaxTokens  int     // count of tokens
+	MaxDepth   int     // nesting of subexpressions, parentheses, lists and calls
+	MaxLiteral float64 // absolute value of numeric literals
+	// AllowedFunctions and AllowedOperators (if not nil) are the only functions and operators
+	// expression can use, DeniedFunctions and DeniedOperators can't be used at all.
+	// Lambdas called by name are not restricted.
+	AllowedFunctions []string
+	DeniedFunctions  []string
+	AllowedOperators []string
+	DeniedOperators  []string
+}
+
+// check returns ErrNotAllowed if expression uses denied or not allowed function or operator,
+// also inside bodies of defined functions it calls
+func (l *Limits) check(n *node, functions map[string]*Function) error {
+	return l.checkNode(n, functions, map[*Function]bool{})
+}
+
+// checkNode checks n, visited functions are not checked again so recursion terminates
+func (l *Limits) checkNode(n *node, functions map[string]*Function, visited map[*Function]bool) error {
+	switch n.Type {
+	case functionNode:
+		fn, ok := lookupFunction(functions, n.SValue, len(n.Args))
+		if ok && !permitted(n.SValue, l.AllowedFunctions, l.DeniedFunctions) {
+			return fmt.Errorf("%w: function '%s'", ErrNotAllowed, n.SValue)
+		}
+		if ok && fn.body != nil && !visited[fn] {
+			visited[fn] = true
+			if err := l.checkNode(fn.body, functions, visited); err != nil {
+				return fmt.Errorf("function '%s': %w", n.SValue, err)
+			}
+		}
+	case operatorNode:
+		if !permitted(n.SValue, l.AllowedOperators, l.DeniedOperators) {
+			return fmt.Errorf("%w: operator '%s'", ErrNotAllowed, n.SValue)
+		}
+	}
+	for _, arg := range n.Args {
+		if err := l.checkNode(arg, functions, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func permitted(name string, allowed, denied []string) bool {
+	for _, d := range denied {
+		if d == name {
+			return false
+		}
+	}
+	if allowed == nil {
+		return true
+	}
+	for _, a := range allowed {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
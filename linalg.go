@@ -0,0 +1,421 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ErrShapeMismatch incompatible shapes of vectors or matrices error
+// ErrSingularMatrix singular matrix error
+var (
+	ErrShapeMismatch  = errors.New("shape mismatch")
+	ErrSingularMatrix = errors.New("singular matrix")
+)
+
+// elementwise applies scalar fn to numbers, lists of numbers or nested lists with broadcasting of numbers
+func elementwise(a, b Value, fn func(a, b float64) (float64, error)) (Value, error) {
+	switch a := a.(type) {
+	case Number:
+		switch b := b.(type) {
+		case Number:
+			res, err := fn(float64(a), float64(b))
+			return Number(res), err
+		case List:
+			return mapList(b, func(item Value) (Value, error) { return elementwise(a, item, fn) })
+		}
+	case List:
+		switch b := b.(type) {
+		case Number:
+			return mapList(a, func(item Value) (Value, error) { return elementwise(item, b, fn) })
+		case List:
+			if len(a) != len(b) {
+				return nil, fmt.Errorf("%w: %s and %s", ErrShapeMismatch, shape(a), shape(b))
+			}
+			res := make(List, len(a))
+			for i := range a {
+				var err error
+				if res[i], err = elementwise(a[i], b[i], fn); err != nil {
+					return nil, err
+				}
+			}
+			return res, nil
+		}
+		return nil, fmt.Errorf("expected number or list, got %s", typeName(b))
+	}
+	return nil, fmt.Errorf("expected number or list, got %s", typeName(a))
+}
+
+func mapList(l List, fn func(item Value) (Value, error)) (Value, error) {
+	res := make(List, len(l))
+	for i, item := range l {
+		var err error
+		if res[i], err = fn(item); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// shape returns human readable shape of value like `3` for vector or `2x3` for matrix
+func shape(v Value) string {
+	l, ok := v.(List)
+	if !ok {
+		return typeName(v)
+	}
+	if len(l) > 0 {
+		if row, ok := l[0].(List); ok {
+			return strconv.Itoa(len(l)) + "x" + strconv.Itoa(len(row))
+		}
+	}
+	return strconv.Itoa(len(l))
+}
+
+func toVector(v Value) ([]float64, error) {
+	floats, err := listFloats(v)
+	if err != nil {
+		return nil, fmt.Errorf("expected vector: %w", err)
+	}
+	return floats, nil
+}
+
+func toMatrix(v Value) ([][]float64, error) {
+	rows, err := asList(v)
+	if err != nil {
+		return nil, fmt.Errorf("expected matrix: %w", err)
+	}
+	m := make([][]float64, len(rows))
+	for i, row := range rows {
+		if m[i], err = toVector(row); err != nil {
+			return nil, fmt.Errorf("expected matrix: %w", err)
+		}
+		if len(m[i]) != len(m[0]) {
+			return nil, fmt.Errorf("%w: rows of matrix have different length", ErrShapeMismatch)
+		}
+	}
+	return m, nil
+}
+
+func toSquareMatrix(v Value) ([][]float64, error) {
+	m, err := toMatrix(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(m) == 0 || len(m) != len(m[0]) {
+		return nil, fmt.Errorf("%w: expected square matrix, got %s", ErrShapeMismatch, shape(v))
+	}
+	return m, nil
+}
+
+func fromVector(v []float64) List {
+	l := make(List, len(v))
+	for i, f := range v {
+		l[i] = Number(f)
+	}
+	return l
+}
+
+func fromMatrix(m [][]float64) List {
+	l := make(List, len(m))
+	for i, row := range m {
+		l[i] = fromVector(row)
+	}
+	return l
+}
+
+func isMatrix(v Value) bool {
+	l, ok := v.(List)
+	if !ok || len(l) == 0 {
+		return false
+	}
+	_, ok = l[0].(List)
+	return ok
+}
+
+// matmul multiplies matrices and vectors. Vector is row at left side and column at right side.
+func matmul(a, b Value) (Value, error) {
+	if !isMatrix(a) && !isMatrix(b) {
+		return dot(a, b)
+	}
+	left, right := a, b
+	if !isMatrix(a) {
+		left = List{a}
+	}
+	if !isMatrix(b) {
+		v, err := toVector(b)
+		if err != nil {
+			return nil, err
+		}
+		col := make(List, len(v))
+		for i, f := range v {
+			col[i] = List{Number(f)}
+		}
+		right = col
+	}
+	x, err := toMatrix(left)
+	if err != nil {
+		return nil, err
+	}
+	y, err := toMatrix(right)
+	if err != nil {
+		return nil, err
+	}
+	if len(x[0]) == 0 || len(x[0]) != len(y) || len(y[0]) == 0 {
+		return nil, fmt.Errorf("%w: %s and %s", ErrShapeMismatch, shape(a), shape(b))
+	}
+	res := make([][]float64, len(x))
+	for i := range x {
+		res[i] = make([]float64, len(y[0]))
+		for j := range y[0] {
+			for k := range y {
+				res[i][j] += x[i][k] * y[k][j]
+			}
+		}
+	}
+	switch {
+	case !isMatrix(a):
+		return fromVector(res[0]), nil
+	case !isMatrix(b):
+		v := make([]float64, len(res))
+		for i, row := range res {
+			v[i] = row[0]
+		}
+		return fromVector(v), nil
+	}
+	return fromMatrix(res), nil
+}
+
+func dot(a, b Value) (Value, error) {
+	x, err := toVector(a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := toVector(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(x) != len(y) {
+		return nil, fmt.Errorf("%w: %s and %s", ErrShapeMismatch, shape(a), shape(b))
+	}
+	var res float64
+	for i := range x {
+		res += x[i] * y[i]
+	}
+	return Number(res), nil
+}
+
+func cross(a, b Value) (Value, error) {
+	x, err := toVector(a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := toVector(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(x) != 3 || len(y) != 3 {
+		return nil, fmt.Errorf("%w: cross product expects 3-vectors, got %s and %s", ErrShapeMismatch, shape(a), shape(b))
+	}
+	return fromVector([]float64{
+		x[1]*y[2] - x[2]*y[1],
+		x[2]*y[0] - x[0]*y[2],
+		x[0]*y[1] - x[1]*y[0],
+	}), nil
+}
+
+// norm returns euclidean norm of vector or Frobenius norm of matrix
+func norm(v Value) (Value, error) {
+	var rows [][]float64
+	if isMatrix(v) {
+		m, err := toMatrix(v)
+		if err != nil {
+			return nil, err
+		}
+		rows = m
+	} else {
+		x, err := toVector(v)
+		if err != nil {
+			return nil, err
+		}
+		rows = [][]float64{x}
+	}
+	var sum float64
+	for _, row := range rows {
+		for _, f := range row {
+			sum += f * f
+		}
+	}
+	return Number(math.Sqrt(sum)), nil
+}
+
+func transpose(v Value) (Value, error) {
+	if !isMatrix(v) {
+		x, err := toVector(v)
+		if err != nil {
+			return nil, err
+		}
+		v = fromMatrix([][]float64{x})
+	}
+	m, err := toMatrix(v)
+	if err != nil {
+		return nil, err
+	}
+	res := make([][]float64, len(m[0]))
+	for j := range res {
+		res[j] = make([]float64, len(m))
+		for i := range m {
+			res[j][i] = m[i][j]
+		}
+	}
+	return fromMatrix(res), nil
+}
+
+// det returns determinant using LU decomposition with partial pivoting
+func det(v Value) (Value, error) {
+	m, err := toSquareMatrix(v)
+	if err != nil {
+		return nil, err
+	}
+	res := 1.0
+	for col := range m {
+		pivot := pivotRow(m, col)
+		if m[pivot][col] == 0 {
+			return Number(0), nil
+		}
+		if pivot != col {
+			m[pivot], m[col] = m[col], m[pivot]
+			res = -res
+		}
+		res *= m[col][col]
+		for row := col + 1; row < len(m); row++ {
+			k := m[row][col] / m[col][col]
+			for j := col; j < len(m); j++ {
+				m[row][j] -= k * m[col][j]
+			}
+		}
+	}
+	return Number(res), nil
+}
+
+func inv(v Value) (Value, error) {
+	m, err := toSquareMatrix(v)
+	if err != nil {
+		return nil, err
+	}
+	identity := make([][]float64, len(m))
+	for i := range identity {
+		identity[i] = make([]float64, len(m))
+		identity[i][i] = 1
+	}
+	res, err := gaussJordan(m, identity)
+	if err != nil {
+		return nil, err
+	}
+	return fromMatrix(res), nil
+}
+
+// solve solves linear system `a @ x = b`, where b is vector or matrix
+func solve(a, b Value) (Value, error) {
+	m, err := toSquareMatrix(a)
+	if err != nil {
+		return nil, err
+	}
+	if isMatrix(b) {
+		rhs, err := toMatrix(b)
+		if err != nil {
+			return nil, err
+		}
+		if len(rhs) != len(m) {
+			return nil, fmt.Errorf("%w: %s and %s", ErrShapeMismatch, shape(a), shape(b))
+		}
+		res, err := gaussJordan(m, rhs)
+		if err != nil {
+			return nil, err
+		}
+		return fromMatrix(res), nil
+	}
+	x, err := toVector(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(x) != len(m) {
+		return nil, fmt.Errorf("%w: %s and %s", ErrShapeMismatch, shape(a), shape(b))
+	}
+	rhs := make([][]float64, len(x))
+	for i, f := range x {
+		rhs[i] = []float64{f}
+	}
+	res, err := gaussJordan(m, rhs)
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range res {
+		x[i] = row[0]
+	}
+	return fromVector(x), nil
+}
+
+// gaussJordan reduces m to identity applying same operations to rhs and returns rhs.
+// Both matrices are modified.
+func gaussJordan(m, rhs [][]float64) ([][]float64, error) {
+	const eps = 1e-12
+	for col := range m {
+		pivot := pivotRow(m, col)
+		if math.Abs(m[pivot][col]) < eps {
+			return nil, ErrSingularMatrix
+		}
+		m[pivot], m[col] = m[col], m[pivot]
+		rhs[pivot], rhs[col] = rhs[col], rhs[pivot]
+		k := m[col][col]
+		for j := range m[col] {
+			m[col][j] /= k
+		}
+		for j := range rhs[col] {
+			rhs[col][j] /= k
+		}
+		for row := range m {
+			if row == col || m[row][col] == 0 {
+				continue
+			}
+			k := m[row][col]
+			for j := range m[row] {
+				m[row][j] -= k * m[col][j]
+			}
+			for j := range rhs[row] {
+				rhs[row][j] -= k * rhs[col][j]
+			}
+		}
+	}
+	return rhs, nil
+}
+
+func pivotRow(m [][]float64, col int) int {
+	pivot := col
+	for row := col + 1; row < len(m); row++ {
+		if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+			pivot = row
+		}
+	}
+	return pivot
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"container/list"
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// memo is bounded LRU cache of function results
+type memo struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List
+}
+
+type memoItem struct {
+	key   string
+	value float64
+}
+
+func newMemo(size int) *memo {
+	return &memo{size: size, items: map[string]*list.Element{}, order: list.New()}
+}
+
+// call returns cached result for args or calls fn and caches its result.
+// Errors are not cached.
+func (m *memo) call(args []float64, fn func() (float64, error)) (float64, error) {
+	key := memoKey(args)
+	m.mu.Lock()
+	if el, ok := m.items[key]; ok {
+		m.order.MoveToFront(el)
+		v := el.Value.(*memoItem).value
+		m.mu.Unlock()
+		return v, nil
+	}
+	m.mu.Unlock()
+	v, err := fn()
+	if err != nil || m.size <= 0 {
+		return v, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[key]; ok {
+		return v, nil
+	}
+	m.items[key] = m.order.PushFront(&memoItem{key: key, value: v})
+	if m.order.Len() > m.size {
+		last := m.order.Back()
+		m.order.Remove(last)
+		delete(m.items, last.Value.(*memoItem).key)
+	}
+	return v, nil
+}
+
+func memoKey(args []float64) string {
+	buf := make([]byte, 8*len(args))
+	for i, arg := range args {
+		binary.LittleEndian.PutUint64(buf[i*8:], math.Float64bits(arg))
+	}
+	return string(buf)
+}
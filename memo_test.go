@@ -0,0 +1,68 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"testing"
+)
+
+func TestMemoize(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	calls := 0
+	c.AddFunction(NewPureFunction("rate", func(args ...float64) (float64, error) {
+		calls++
+		return args[0] * 2, nil
+	}, 1).Memoize(2))
+	impure := &Function{Name: "now", Fn: func(args ...float64) (float64, error) {
+		calls++
+		return 1, nil
+	}, Pure: true}
+	c.AddFunction(impure.Memoize(2))
+	if err := c.Prepare("rate(x) + 1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []float64{1, 2, 1, 2, 3, 1} {
+		actual, err := c.Execute(map[string]float64{"x": x})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != x*2+1 {
+			t.Errorf("Expected %f, actual %f", x*2+1, actual)
+		}
+	}
+	if calls != 4 {
+		t.Errorf("Expected 4 calls, actual %d", calls)
+	}
+
+	calls = 0
+	if err := c.Prepare("now() + now()"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Execute(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 4 {
+		t.Errorf("Expected 4 calls of not deterministic function, actual %d", calls)
+	}
+}
@@ -0,0 +1,437 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// MinimizeMethod is numeric method of minimization
+type MinimizeMethod int
+
+// AutoMethod uses BFGSMethod if expression supports ExecuteGradient and NelderMeadMethod otherwise
+// NelderMeadMethod uses only values of expression
+// BFGSMethod uses gradients of expression
+const (
+	AutoMethod MinimizeMethod = iota
+	NelderMeadMethod
+	BFGSMethod
+)
+
+// MinimizeOptions configures Minimize and Maximize
+type MinimizeOptions struct {
+	Vars          map[string]float64 // values of fixed variables
+	Start         map[string]float64 // initial values of optimized variables
+	Lower, Upper  map[string]float64 // optional bounds of optimized variables
+	Method        MinimizeMethod
+	Tolerance     float64 // DefaultTolerance if zero
+	MaxIterations int     // 200 per optimized variable if zero
+}
+
+// Optimum is result of Minimize and Maximize
+type Optimum struct {
+	X          map[string]float64 // values of optimized variables
+	Value      float64            // value of expression at X
+	Method     MinimizeMethod     // method actually used
+	Iterations int
+	Converged  bool
+	Reason     string // why method stopped without convergence
+}
+
+// Minimize finds values of variables of opts.Start that minimize expression within bounds.
+// Optimum is returned even if method doesn't converge, see its Converged and Reason.
+// Prepared expression of calculator is not changed.
+func (c *Calc) Minimize(expression string, opts MinimizeOptions) (Optimum, error) {
+	return c.minimize(expression, opts, 1)
+}
+
+// Maximize finds values of variables of opts.Start that maximize expression within bounds
+func (c *Calc) Maximize(expression string, opts MinimizeOptions) (Optimum, error) {
+	return c.minimize(expression, opts, -1)
+}
+
+func (c *Calc) minimize(expression string, opts MinimizeOptions, sign float64) (Optimum, error) {
+	if len(opts.Start) == 0 {
+		return Optimum{}, fmt.Errorf("no variables to optimize")
+	}
+	t := c.newTokenizer(expression, c.limits)
+	if err := t.tokenize(); err != nil {
+		return Optimum{}, err
+	}
+	root, err := c.newParser(expression, t.tkns, c.limits).parseScript()
+	if err != nil {
+		return Optimum{}, err
+	}
+	if err := c.limits.check(root, c.functions); err != nil {
+		return Optimum{}, err
+	}
+	p := newProgram(root, c)
+	m := &minimizer{p: p, sign: sign, tol: opts.Tolerance, maxIter: opts.MaxIterations}
+	vars := make(map[string]float64, len(opts.Vars)+len(opts.Start))
+	for name, v := range opts.Vars {
+		vars[name] = v
+	}
+	for name, v := range opts.Start {
+		if _, ok := p.layout[name]; !ok {
+			return Optimum{}, fmt.Errorf("expression doesn't depend on variable '%s'", name)
+		}
+		vars[name] = v
+		m.names = append(m.names, name)
+	}
+	sort.Strings(m.names)
+	if m.slots, err = p.slotsOf(vars); err != nil {
+		return Optimum{}, err
+	}
+	x := make([]float64, len(m.names))
+	m.lower, m.upper = make([]float64, len(m.names)), make([]float64, len(m.names))
+	for i, name := range m.names {
+		m.lower[i], m.upper[i] = math.Inf(-1), math.Inf(1)
+		if v, ok := opts.Lower[name]; ok {
+			m.lower[i] = v
+		}
+		if v, ok := opts.Upper[name]; ok {
+			m.upper[i] = v
+		}
+		if m.lower[i] > m.upper[i] {
+			return Optimum{}, fmt.Errorf("invalid bounds of '%s': [%g, %g]", name, m.lower[i], m.upper[i])
+		}
+		x[i] = opts.Start[name]
+	}
+	m.clamp(x)
+	if m.tol <= 0 {
+		m.tol = DefaultTolerance
+	}
+	if m.maxIter <= 0 {
+		m.maxIter = 200 * len(m.names)
+	}
+	method := opts.Method
+	if method == AutoMethod {
+		method = NelderMeadMethod
+		if _, _, err := m.gradient(x); err == nil {
+			method = BFGSMethod
+		}
+	}
+	res := Optimum{Method: method}
+	if method == BFGSMethod {
+		x, err = m.bfgs(x, &res)
+	} else {
+		x, err = m.nelderMead(x, &res)
+	}
+	if err != nil {
+		return Optimum{}, err
+	}
+	res.X = make(map[string]float64, len(m.names))
+	for i, name := range m.names {
+		res.X[name] = x[i]
+	}
+	return res, nil
+}
+
+// minimizer minimizes sign * program by some of its variables
+type minimizer struct {
+	p            *program
+	names        []string
+	slots        []float64
+	lower, upper []float64
+	sign         float64
+	tol          float64
+	maxIter      int
+}
+
+// f returns sign * value of program at x
+func (m *minimizer) f(x []float64) (float64, error) {
+	for i, name := range m.names {
+		m.slots[m.p.layout[name]] = x[i]
+	}
+	res, err := m.p.executeSlots(m.slots)
+	return m.sign * res, err
+}
+
+// gradient returns sign * value and gradient of program at x
+func (m *minimizer) gradient(x []float64) (float64, []float64, error) {
+	for i, name := range m.names {
+		m.slots[m.p.layout[name]] = x[i]
+	}
+	res, grad, err := m.p.executeGradient(m.slots, m.names)
+	for i := range grad {
+		grad[i] *= m.sign
+	}
+	return m.sign * res, grad, err
+}
+
+// clamp moves x into bounds
+func (m *minimizer) clamp(x []float64) {
+	for i := range x {
+		x[i] = math.Max(m.lower[i], math.Min(m.upper[i], x[i]))
+	}
+}
+
+// nelderMead implements Nelder-Mead simplex method, vertices are clamped into bounds
+func (m *minimizer) nelderMead(start []float64, res *Optimum) ([]float64, error) {
+	n := len(start)
+	simplex := make([][]float64, n+1)
+	values := make([]float64, n+1)
+	for i := range simplex {
+		simplex[i] = append([]float64(nil), start...)
+		if i > 0 {
+			step := 0.05 * math.Abs(start[i-1])
+			if step == 0 {
+				step = 0.00025
+			}
+			if simplex[i][i-1]+step > m.upper[i-1] {
+				step = -step
+			}
+			simplex[i][i-1] += step
+			m.clamp(simplex[i])
+		}
+		var err error
+		if values[i], err = m.f(simplex[i]); err != nil {
+			return nil, err
+		}
+	}
+	// point returns centroid + t * (centroid - worst) clamped into bounds
+	point := func(centroid, worst []float64, t float64) []float64 {
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = centroid[i] + t*(centroid[i]-worst[i])
+		}
+		m.clamp(x)
+		return x
+	}
+	for res.Iterations = 1; res.Iterations <= m.maxIter; res.Iterations++ {
+		sort.Sort(&vertices{simplex, values})
+		if m.nelderMeadConverged(simplex, values) {
+			res.Converged = true
+			break
+		}
+		centroid := make([]float64, n)
+		for _, v := range simplex[:n] {
+			for i := range centroid {
+				centroid[i] += v[i] / float64(n)
+			}
+		}
+		worst := simplex[n]
+		reflected := point(centroid, worst, 1)
+		fr, err := m.f(reflected)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case fr < values[0]:
+			expanded := point(centroid, worst, 2)
+			fe, err := m.f(expanded)
+			if err != nil {
+				return nil, err
+			}
+			if fe < fr {
+				simplex[n], values[n] = expanded, fe
+			} else {
+				simplex[n], values[n] = reflected, fr
+			}
+			continue
+		case fr < values[n-1]:
+			simplex[n], values[n] = reflected, fr
+			continue
+		}
+		t := -0.5 // inside contraction
+		if fr < values[n] {
+			t = 0.5 // outside contraction
+		}
+		contracted := point(centroid, worst, t)
+		fc, err := m.f(contracted)
+		if err != nil {
+			return nil, err
+		}
+		if fc < math.Min(fr, values[n]) {
+			simplex[n], values[n] = contracted, fc
+			continue
+		}
+		for i := 1; i <= n; i++ {
+			for j := range simplex[i] {
+				simplex[i][j] = simplex[0][j] + 0.5*(simplex[i][j]-simplex[0][j])
+			}
+			if values[i], err = m.f(simplex[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Sort(&vertices{simplex, values})
+	if !res.Converged {
+		res.Iterations = m.maxIter
+		res.Reason = "iterations limit exceeded"
+	}
+	res.Value = m.sign * values[0]
+	return simplex[0], nil
+}
+
+// nelderMeadConverged reports whether values and vertices of sorted simplex are close to best one
+func (m *minimizer) nelderMeadConverged(simplex [][]float64, values []float64) bool {
+	xtol := math.Sqrt(m.tol)
+	for i := 1; i < len(simplex); i++ {
+		if math.Abs(values[i]-values[0]) > m.tol*(1+math.Abs(values[0])) {
+			return false
+		}
+		for j := range simplex[i] {
+			if math.Abs(simplex[i][j]-simplex[0][j]) > xtol*(1+math.Abs(simplex[0][j])) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// vertices sorts simplex by values
+type vertices struct {
+	simplex [][]float64
+	values  []float64
+}
+
+func (v *vertices) Len() int           { return len(v.values) }
+func (v *vertices) Less(i, j int) bool { return v.values[i] < v.values[j] }
+func (v *vertices) Swap(i, j int) {
+	v.simplex[i], v.simplex[j] = v.simplex[j], v.simplex[i]
+	v.values[i], v.values[j] = v.values[j], v.values[i]
+}
+
+// bfgs implements BFGS method with backtracking line search projected into bounds
+func (m *minimizer) bfgs(x []float64, res *Optimum) ([]float64, error) {
+	n := len(x)
+	f, g, err := m.gradient(x)
+	if err != nil {
+		return nil, err
+	}
+	h := identity(n)
+	for res.Iterations = 1; res.Iterations <= m.maxIter; res.Iterations++ {
+		if m.projectedNorm(x, g) <= m.tol*(1+math.Abs(f)) {
+			res.Converged = true
+			break
+		}
+		d := m.direction(x, h, g)
+		if dotFloats(d, g) >= 0 {
+			h = identity(n)
+			d = m.direction(x, h, g)
+		}
+		next, fn := make([]float64, n), 0.0
+		found := false
+		for t := 1.0; t > 1e-20; t /= 2 {
+			for i := range next {
+				next[i] = x[i] + t*d[i]
+			}
+			m.clamp(next)
+			if fn, err = m.f(next); err != nil {
+				return nil, err
+			}
+			s := make([]float64, n)
+			for i := range s {
+				s[i] = next[i] - x[i]
+			}
+			if fn <= f+1e-4*dotFloats(g, s) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res.Reason = "line search failed"
+			break
+		}
+		fn, gn, err := m.gradient(next)
+		if err != nil {
+			return nil, err
+		}
+		s, y := make([]float64, n), make([]float64, n)
+		for i := range s {
+			s[i], y[i] = next[i]-x[i], gn[i]-g[i]
+		}
+		if sy := dotFloats(s, y); sy > 1e-12 {
+			updateInverseHessian(h, s, y, sy)
+		}
+		small := math.Abs(fn-f) <= m.tol*(1+math.Abs(f)) && math.Sqrt(dotFloats(s, s)) <= math.Sqrt(m.tol)*(1+math.Sqrt(dotFloats(x, x)))
+		x, f, g = next, fn, gn
+		if small {
+			res.Converged = true
+			break
+		}
+	}
+	if !res.Converged && res.Reason == "" {
+		res.Iterations = m.maxIter
+		res.Reason = "iterations limit exceeded"
+	}
+	res.Value = m.sign * f
+	return x, nil
+}
+
+// direction returns -h*g without components that leave bounds
+func (m *minimizer) direction(x []float64, h [][]float64, g []float64) []float64 {
+	d := make([]float64, len(x))
+	for i := range d {
+		for j := range g {
+			d[i] -= h[i][j] * g[j]
+		}
+		if (x[i] <= m.lower[i] && d[i] < 0) || (x[i] >= m.upper[i] && d[i] > 0) {
+			d[i] = 0
+		}
+	}
+	return d
+}
+
+// projectedNorm returns norm of gradient step projected into bounds, it is zero at constrained minimum
+func (m *minimizer) projectedNorm(x, g []float64) float64 {
+	var res float64
+	for i := range x {
+		d := math.Max(m.lower[i], math.Min(m.upper[i], x[i]-g[i])) - x[i]
+		res += d * d
+	}
+	return math.Sqrt(res)
+}
+
+// updateInverseHessian applies BFGS update to inverse Hessian approximation h
+func updateInverseHessian(h [][]float64, s, y []float64, sy float64) {
+	n := len(s)
+	hy := make([]float64, n)
+	for i := range hy {
+		hy[i] = dotFloats(h[i], y)
+	}
+	yhy := dotFloats(y, hy)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			h[i][j] += (sy+yhy)*s[i]*s[j]/(sy*sy) - (hy[i]*s[j]+s[i]*hy[j])/sy
+		}
+	}
+}
+
+func identity(n int) [][]float64 {
+	res := make([][]float64, n)
+	for i := range res {
+		res[i] = make([]float64, n)
+		res[i][i] = 1
+	}
+	return res
+}
+
+func dotFloats(a, b []float64) float64 {
+	var res float64
+	for i := range a {
+		res += a[i] * b[i]
+	}
+	return res
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMinimize(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddFunctions(MathFunctions)
+	c.AddFunction(NewPureFunction("sq", func(args ...float64) (float64, error) { return args[0] * args[0], nil }, 1))
+	rosenbrock := "(1 - x)^2 + k * (y - x^2)^2"
+	tests := []struct {
+		name       string
+		expression string
+		opts       MinimizeOptions
+		method     MinimizeMethod
+		want       map[string]float64
+		value      float64
+	}{
+		{"bfgs", rosenbrock, MinimizeOptions{Vars: map[string]float64{"k": 100}, Start: map[string]float64{"x": -1.2, "y": 1}}, BFGSMethod, map[string]float64{"x": 1, "y": 1}, 0},
+		{"nelder mead", rosenbrock, MinimizeOptions{Vars: map[string]float64{"k": 100}, Start: map[string]float64{"x": -1.2, "y": 1}, Method: NelderMeadMethod}, NelderMeadMethod, map[string]float64{"x": 1, "y": 1}, 0},
+		{"without gradient", "sq(x - 2) + sq(y + 1) + 3", MinimizeOptions{Start: map[string]float64{"x": 0, "y": 0}}, NelderMeadMethod, map[string]float64{"x": 2, "y": -1}, 3},
+		{"fixed variable", rosenbrock, MinimizeOptions{Vars: map[string]float64{"k": 100, "y": 4}, Start: map[string]float64{"x": 1}}, BFGSMethod, map[string]float64{"x": 1.99938}, 0.99938},
+		{"bounds bfgs", "(x - 3)^2 + (y - 1)^2", MinimizeOptions{Start: map[string]float64{"x": 0, "y": 0}, Upper: map[string]float64{"x": 2}}, BFGSMethod, map[string]float64{"x": 2, "y": 1}, 1},
+		{"bounds nelder mead", "(x - 3)^2 + (y - 1)^2", MinimizeOptions{Start: map[string]float64{"x": 0, "y": 0}, Upper: map[string]float64{"x": 2}, Method: NelderMeadMethod}, NelderMeadMethod, map[string]float64{"x": 2, "y": 1}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Minimize(tt.expression, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Converged || got.Method != tt.method {
+				t.Errorf("expected convergence by method %d, got %+v", tt.method, got)
+			}
+			for name, want := range tt.want {
+				if math.Abs(got.X[name]-want) > 1e-3 {
+					t.Errorf("X[%s] = %v, want %v", name, got.X[name], want)
+				}
+			}
+			if math.Abs(got.Value-tt.value) > 1e-3 {
+				t.Errorf("Value = %v, want %v", got.Value, tt.value)
+			}
+		})
+	}
+}
+
+func TestMaximize(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	// revenue of price p with demand 100 - 2 * p
+	got, err := c.Maximize("p * (100 - 2 * p) - cost", MinimizeOptions{Vars: map[string]float64{"cost": 50}, Start: map[string]float64{"p": 10}, Lower: map[string]float64{"p": 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Converged || math.Abs(got.X["p"]-25) > 1e-6 || math.Abs(got.Value-1200) > 1e-6 {
+		t.Errorf("expected maximum 1200 at p = 25, got %+v", got)
+	}
+	got, err = c.Minimize("x^2 + y^2", MinimizeOptions{Start: map[string]float64{"x": 1, "y": 1}, Method: NelderMeadMethod, MaxIterations: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Converged || got.Iterations != 3 || got.Reason == "" {
+		t.Errorf("expected not converged result after 3 iterations, got %+v", got)
+	}
+	if _, err := c.Minimize("x^2", MinimizeOptions{Start: map[string]float64{"z": 1}}); err == nil {
+		t.Error("expected error of missing variable z")
+	}
+	if _, err := c.Minimize("x^2 + y", MinimizeOptions{Start: map[string]float64{"x": 1}}); err == nil {
+		t.Error("expected error of unknown variable y")
+	}
+}
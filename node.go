@@ -0,0 +1,195 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"strconv"
+	"strings"
+)
+
+type nodeType int
+
+const (
+	literalNode nodeType = iota
+	variableNode
+	operatorNode
+	functionNode
+	negNode
+	conditionNode
+	assignNode
+	letNode
+	scriptNode
+	listNode
+	indexNode
+	lambdaNode
+	memberNode
+)
+
+// node is a node of parsed expression tree
+type node struct {
+	Type   nodeType
+	SValue string
+	FValue float64
+	Args   []*node
+	Params []string
+	Slot   int    // index of free variable in program layout, -1 for bound variables
+	Pos    int    // byte offset of node in source
+	Text   string // source text of node
+	Cache  int    // 1-based index of result cached during execution, 0 if not cached
+}
+
+func newNode(ntype nodeType, SValue string, FValue float64, args ...*node) *node {
+	return &node{Type: ntype, SValue: SValue, FValue: FValue, Args: args, Slot: -1}
+}
+
+// height returns count of nodes on the longest path from node to a leaf.
+// It doesn't recurse, so it is safe for trees of any depth.
+func (n *node) height() int {
+	type item struct {
+		n     *node
+		depth int
+	}
+	res := 0
+	stack := []item{{n, 1}}
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if it.depth > res {
+			res = it.depth
+		}
+		for _, arg := range it.n.Args {
+			stack = append(stack, item{arg, it.depth + 1})
+		}
+	}
+	return res
+}
+
+// format returns source of node, operators are used to place parentheses
+func (n *node) format(operators map[string]*Operator) string {
+	var sb strings.Builder
+	n.write(&sb, operators)
+	return sb.String()
+}
+
+func (n *node) write(sb *strings.Builder, operators map[string]*Operator) {
+	switch n.Type {
+	case literalNode:
+		sb.WriteString(strconv.FormatFloat(n.FValue, 'g', -1, 64))
+	case variableNode:
+		sb.WriteString(n.SValue)
+	case negNode:
+		sb.WriteString("-")
+		// operand starting with literal would be read back as negative literal, like `-2 ^ x` as `(-2) ^ x`
+		arg := n.Args[0]
+		arg.writeOperand(sb, operators, arg.Type == operatorNode || arg.Type == negNode || arg.Type == literalNode && arg.FValue < 0)
+	case operatorNode:
+		priority, assoc := n.priority(operators), LeftAssoc
+		if op, ok := operators[n.SValue]; ok {
+			assoc = op.Assoc
+		}
+		left, right := n.Args[0], n.Args[1]
+		leftParens := left.Type == negNode && priority >= negPriority(operators) || left.Type == operatorNode && (left.priority(operators) < priority || left.priority(operators) == priority && assoc == RightAssoc)
+		rightParens := right.Type == operatorNode && (right.priority(operators) < priority || right.priority(operators) == priority && assoc == LeftAssoc)
+		left.writeOperand(sb, operators, leftParens)
+		sb.WriteString(" " + n.SValue + " ")
+		right.writeOperand(sb, operators, rightParens)
+	case functionNode:
+		sb.WriteString(n.SValue)
+		writeList(sb, operators, "(", n.Args, ")")
+	case conditionNode:
+		n.Args[0].writeOperand(sb, operators, false)
+		sb.WriteString(" ? ")
+		n.Args[1].writeOperand(sb, operators, false)
+		sb.WriteString(" : ")
+		n.Args[2].write(sb, operators)
+	case assignNode, letNode:
+		if n.Type == letNode {
+			sb.WriteString("let ")
+		}
+		sb.WriteString(n.SValue + " = ")
+		n.Args[0].write(sb, operators)
+	case scriptNode:
+		for i, stmt := range n.Args {
+			if i > 0 {
+				sb.WriteString("; ")
+			}
+			stmt.write(sb, operators)
+		}
+	case listNode:
+		writeList(sb, operators, "[", n.Args, "]")
+	case indexNode:
+		n.Args[0].writeOperand(sb, operators, !n.Args[0].isPrimary())
+		writeList(sb, operators, "[", n.Args[1:], "]")
+	case memberNode:
+		n.Args[0].writeOperand(sb, operators, !n.Args[0].isPrimary())
+		sb.WriteString("." + n.SValue)
+	case lambdaNode:
+		if len(n.Params) == 1 {
+			sb.WriteString(n.Params[0])
+		} else {
+			sb.WriteString("(" + strings.Join(n.Params, ", ") + ")")
+		}
+		sb.WriteString(" => ")
+		n.Args[0].write(sb, operators)
+	}
+}
+
+// writeOperand writes node as operand of other node.
+// Conditions, assignments and lambdas are always parenthesized.
+func (n *node) writeOperand(sb *strings.Builder, operators map[string]*Operator, parens bool) {
+	switch n.Type {
+	case conditionNode, assignNode, letNode, scriptNode, lambdaNode:
+		parens = true
+	}
+	if parens {
+		sb.WriteString("(")
+	}
+	n.write(sb, operators)
+	if parens {
+		sb.WriteString(")")
+	}
+}
+
+func (n *node) isPrimary() bool {
+	switch n.Type {
+	case variableNode, functionNode, listNode, indexNode, memberNode:
+		return true
+	}
+	return false
+}
+
+func (n *node) priority(operators map[string]*Operator) int {
+	if op, ok := operators[n.SValue]; ok {
+		return op.Priority
+	}
+	return 0
+}
+
+func writeList(sb *strings.Builder, operators map[string]*Operator, open string, items []*node, close string) {
+	sb.WriteString(open)
+	for i, item := range items {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		item.write(sb, operators)
+	}
+	sb.WriteString(close)
+}
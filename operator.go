@@ -20,12 +20,16 @@
 
 package executor
 
-// Operator implements math operators
+// Operator implements math operators.
+// Fn is applied elementwise to lists, ValueFn (if set) accepts any values as is.
+// Partials (if set) returns partial derivatives of Fn by a and b for ExecuteGradient.
 type Operator struct {
 	Op       string
 	Priority int
 	Assoc    Assoc
 	Fn       func(a float64, b float64) (float64, error)
+	ValueFn  func(a Value, b Value) (Value, error)
+	Partials func(a float64, b float64) (float64, float64)
 }
 
 // NewOperator returns new instance of Operator
@@ -33,6 +37,11 @@ func NewOperator(op string, priority int, assoc Assoc, fn func(a float64, b floa
 	return &Operator{Op: op, Priority: priority, Assoc: assoc, Fn: fn}
 }
 
+// NewValueOperator returns new instance of Operator that accepts any values
+func NewValueOperator(op string, priority int, assoc Assoc, fn func(a Value, b Value) (Value, error)) *Operator {
+	return &Operator{Op: op, Priority: priority, Assoc: assoc, ValueFn: fn}
+}
+
 // Assoc right or left association of operator
 type Assoc int
 
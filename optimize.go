@@ -0,0 +1,183 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+// optimize folds constant sub-expressions and applies algebraic identities.
+// Identities are applied only to operators `+`, `-`, `*`, `/` and `^`.
+// Rewrites that are not exact for NaN, Inf or rounding are applied only with fastMath.
+func (p *program) optimize(n *node, fastMath bool) *node {
+	for i, arg := range n.Args {
+		n.Args[i] = p.optimize(arg, fastMath)
+	}
+	switch n.Type {
+	case negNode:
+		return negate(n)
+	case operatorNode:
+		op, ok := p.operators[n.SValue]
+		if !ok || op.Fn == nil || op.ValueFn != nil {
+			return n
+		}
+		left, right := n.Args[0], n.Args[1]
+		if left.Type == literalNode && right.Type == literalNode {
+			if res, err := op.Fn(left.FValue, right.FValue); err == nil {
+				return folded(n, res)
+			}
+			return n
+		}
+		return p.simplify(n, op, fastMath)
+	case functionNode:
+		fn, ok := lookupFunction(p.functions, n.SValue, len(n.Args))
+		if !ok || !fn.foldable() || fn.Places != len(n.Args) || p.shadowed[n.SValue] {
+			return n
+		}
+		args := make([]float64, len(n.Args))
+		for i, arg := range n.Args {
+			if arg.Type != literalNode {
+				return n
+			}
+			args[i] = arg.FValue
+		}
+		if res, err := fn.Fn(args...); err == nil {
+			return folded(n, res)
+		}
+	case conditionNode:
+		if cond := n.Args[0]; cond.Type == literalNode {
+			if cond.FValue != 0 {
+				return n.Args[1]
+			}
+			return n.Args[2]
+		}
+	}
+	return n
+}
+
+// shadowingNames adds names of assignments, let-bindings and lambda parameters under n to names
+func shadowingNames(n *node, names map[string]bool) map[string]bool {
+	switch n.Type {
+	case assignNode, letNode:
+		names[n.SValue] = true
+	case lambdaNode:
+		for _, name := range n.Params {
+			names[name] = true
+		}
+	}
+	for _, arg := range n.Args {
+		shadowingNames(arg, names)
+	}
+	return names
+}
+
+// simplify applies identities like `x*1 = x` and `x+0 = x` to operator node
+func (p *program) simplify(n *node, op *Operator, fastMath bool) *node {
+	left, right := n.Args[0], n.Args[1]
+	switch op.Op {
+	case "+":
+		if isConst(right, 0) {
+			return left
+		}
+		if isConst(left, 0) {
+			return right
+		}
+	case "-":
+		if isConst(right, 0) {
+			return left
+		}
+		if isConst(left, 0) {
+			return negate(newNode(negNode, "", 0, right))
+		}
+		if fastMath && sameVariable(left, right) {
+			return folded(n, 0)
+		}
+	case "*":
+		if isConst(right, 1) {
+			return left
+		}
+		if isConst(left, 1) {
+			return right
+		}
+		if fastMath && (isConst(left, 0) || isConst(right, 0)) {
+			return folded(n, 0)
+		}
+	case "/":
+		if isConst(right, 1) {
+			return left
+		}
+		if fastMath && sameVariable(left, right) {
+			return folded(n, 1)
+		}
+	case "^":
+		if isConst(right, 1) {
+			return left
+		}
+		if fastMath && isConst(right, 0) {
+			return folded(n, 1)
+		}
+	}
+	if !fastMath || op.Op != "+" && op.Op != "*" {
+		return n
+	}
+	// (x + a) + b = x + (a + b), (x * a) * b = x * (a * b)
+	if right.Type == literalNode && left.Type == operatorNode && left.SValue == op.Op && left.Args[1].Type == literalNode {
+		if res, err := op.Fn(left.Args[1].FValue, right.FValue); err == nil {
+			n.Args = []*node{left.Args[0], folded(right, res)}
+		}
+	}
+	// a + (b + x) = (a + b) + x, a * (b * x) = (a * b) * x
+	if left.Type == literalNode && right.Type == operatorNode && right.SValue == op.Op && right.Args[0].Type == literalNode {
+		if res, err := op.Fn(left.FValue, right.Args[0].FValue); err == nil {
+			n.Args = []*node{folded(left, res), right.Args[1]}
+		}
+	}
+	return n
+}
+
+// folded returns literal node with span of n
+// negate folds negation of literal, negation of negation and negation of product or
+// quotient with negative literal at left
+func negate(n *node) *node {
+	arg := n.Args[0]
+	switch {
+	case arg.Type == literalNode:
+		return folded(n, -arg.FValue)
+	case arg.Type == negNode:
+		return arg.Args[0]
+	case arg.Type == operatorNode && (arg.SValue == "*" || arg.SValue == "/") &&
+		arg.Args[0].Type == literalNode && arg.Args[0].FValue < 0:
+		res := *arg
+		res.Args = []*node{folded(arg.Args[0], -arg.Args[0].FValue), arg.Args[1]}
+		return &res
+	}
+	return n
+}
+
+func folded(n *node, v float64) *node {
+	res := newNode(literalNode, "", v)
+	res.Pos, res.Text = n.Pos, n.Text
+	return res
+}
+
+func isConst(n *node, v float64) bool {
+	return n.Type == literalNode && n.FValue == v
+}
+
+func sameVariable(a, b *node) bool {
+	return a.Type == variableNode && b.Type == variableNode && a.SValue == b.SValue
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddOperators(LogicOperators)
+	calls := 0
+	c.AddFunction(NewPureFunction("sqrt", func(args ...float64) (float64, error) {
+		calls++
+		return math.Sqrt(args[0]), nil
+	}, 1))
+	c.AddFunction(NewFunction("rand", func(args ...float64) (float64, error) { return 4, nil }, 0))
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"2*3*x", "6 * x"},
+		{"sqrt(16) + x", "4 + x"},
+		{"sqrt(rand()) + x", "sqrt(rand()) + x"},
+		{"(x * 1 + 0) ^ 1 - 0", "x"},
+		{"1 > 0 ? x / 1 : y", "x"},
+		{"-(-x)", "x"},
+		{"-(2 + 3) * x", "-5 * x"},
+		{"0 - (x - 1)", "-(x - 1)"},
+		{"-(-2 * x)", "2 * x"},
+		{"x * 0 + (x - x) + x * 2 * 3", "x * 0 + (x - x) + x * 2 * 3"},
+	}
+	for _, test := range tests {
+		if err := c.Prepare(test.expression); err != nil {
+			t.Fatal(err)
+		}
+		if actual := c.program.root.format(c.operators); actual != test.expected {
+			t.Errorf("%s: expected %s, actual %s", test.expression, test.expected, actual)
+		}
+	}
+	for _, expression := range []string{"let sqrt = x => x * 10; sqrt(4)", "sqrt = x => x * 10; sqrt(4)", "apply = sqrt => sqrt(4); apply(x => x * 10)"} {
+		if err := c.Prepare(expression); err != nil {
+			t.Fatal(err)
+		}
+		if actual, err := c.Execute(nil); err != nil || actual != 40 {
+			t.Errorf("%s: expected 40, actual %f, %v", expression, actual, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call of pure function, actual %d", calls)
+	}
+
+	c.SetFastMath(true)
+	if err := c.Prepare("x * 0 + (x - x) + x * 2 * 3"); err != nil {
+		t.Fatal(err)
+	}
+	if actual := c.program.root.format(c.operators); actual != "x * 6" {
+		t.Errorf("Expected x * 6, actual %s", actual)
+	}
+
+	c.SetNumericPolicy(DivisionByZeroPolicy, 0)
+	if err := c.Prepare("x + 1 / 0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Execute(map[string]float64{"x": 1}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("Expected %v, got %v", ErrDivisionByZero, err)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Vars is values of variables of one row
+type Vars map[string]float64
+
+// rowResult is result of one row of parallel evaluation
+type rowResult struct {
+	row   int
+	value float64
+	err   error
+}
+
+// EvaluateParallel executes prepared expression for every row received from rows using
+// pool of workers. If workers is less than 1, GOMAXPROCS workers are used.
+// Results are returned in input order, failed rows are reported by BatchError and get NaN.
+// Evaluation stops when ctx is done, then error of ctx is returned.
+func (c *Calc) EvaluateParallel(ctx context.Context, rows <-chan Vars, workers int) ([]float64, error) {
+	if c.program == nil {
+		return nil, &NotPreparedError{}
+	}
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	p := c.program
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type job struct {
+		row  int
+		vars Vars
+	}
+	jobs := make(chan job, workers)
+	results := make(chan rowResult, workers)
+	go func() {
+		defer close(jobs)
+		for row := 0; ; row++ {
+			select {
+			case <-ctx.Done():
+				return
+			case vars, ok := <-rows:
+				if !ok {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case jobs <- job{row: row, vars: vars}:
+				}
+			}
+		}
+	}()
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				v, err := p.execute(j.vars)
+				select {
+				case <-ctx.Done():
+					return
+				case results <- rowResult{row: j.row, value: v, err: err}:
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	var out []float64
+	var errs BatchError
+	for r := range results {
+		for len(out) <= r.row {
+			out = append(out, 0)
+		}
+		if r.err != nil {
+			out[r.row] = math.NaN()
+			errs = append(errs, &RowError{Row: r.row, Err: r.err})
+			continue
+		}
+		out[r.row] = r.value
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Row < errs[j].Row })
+		return out, errs
+	}
+	return out, nil
+}
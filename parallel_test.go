@@ -0,0 +1,80 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEvaluateParallel(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	if err := c.Prepare("x * 2 + 1 / y"); err != nil {
+		t.Fatal(err)
+	}
+	rows := make(chan Vars)
+	go func() {
+		defer close(rows)
+		for i := 0; i < 100; i++ {
+			vars := Vars{"x": float64(i)}
+			if i%10 != 0 {
+				vars["y"] = float64(i % 10)
+			}
+			rows <- vars
+		}
+	}()
+	out, err := c.EvaluateParallel(context.Background(), rows, 4)
+	var batchErr BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected BatchError, actual %v", err)
+	}
+	if len(batchErr) != 10 {
+		t.Errorf("Expected 10 failed rows, actual %d", len(batchErr))
+	}
+	for i, rowErr := range batchErr {
+		if rowErr.Row != i*10 {
+			t.Errorf("Expected failed row %d, actual %d", i*10, rowErr.Row)
+		}
+	}
+	if len(out) != 100 {
+		t.Fatalf("Expected 100 results, actual %d", len(out))
+	}
+	for i, v := range out {
+		if i%10 == 0 {
+			if !math.IsNaN(v) {
+				t.Errorf("Row %d: expected NaN, actual %f", i, v)
+			}
+			continue
+		}
+		if expected := float64(i)*2 + 1/float64(i%10); v != expected {
+			t.Errorf("Row %d: expected %f, actual %f", i, expected, v)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.EvaluateParallel(ctx, make(chan Vars), 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, actual %v", err)
+	}
+}
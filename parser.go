@@ -0,0 +1,353 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"fmt"
+	"math"
+)
+
+// parser builds expression tree from tokens
+type parser struct {
+	src         string // source of tokens, if set nodes get its text
+	tkns        []*token
+	pos         int
+	operators   map[string]*Operator
+	lets        map[string]bool
+	negPriority int
+	depth       int
+	maxDepth    int
+}
+
+func newParser(tkns []*token, operators map[string]*Operator) *parser {
+	return &parser{tkns: tkns, operators: operators, lets: map[string]bool{}, negPriority: negPriority(operators), maxDepth: DefaultMaxDepth}
+}
+
+// negPriority returns priority of unary minus: it binds tighter than any left associated operator
+func negPriority(operators map[string]*Operator) int {
+	res := 0
+	for _, op := range operators {
+		if op.Assoc == LeftAssoc && op.Priority >= res {
+			res = op.Priority + 1
+		}
+	}
+	return res
+}
+
+func (p *parser) peek(offset int) *token {
+	if p.pos+offset >= len(p.tkns) {
+		return &token{Type: eof}
+	}
+	return p.tkns[p.pos+offset]
+}
+
+func (p *parser) next() *token {
+	tkn := p.peek(0)
+	p.pos++
+	return tkn
+}
+
+// span sets position of node from start to end of last consumed token
+func (p *parser) span(n *node, start int) *node {
+	n.Pos = start
+	if end := p.tkns[p.pos-1].End; p.src != "" && start <= end && end <= len(p.src) {
+		n.Text = p.src[start:end]
+	}
+	return n
+}
+
+// parseScript parses statements separated by `;`
+func (p *parser) parseScript() (*node, error) {
+	var stmts []*node
+	for p.peek(0).Type != eof {
+		if p.peek(0).Type == semicolonType {
+			p.pos++
+			continue
+		}
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+		switch p.next().Type {
+		case semicolonType, eof:
+		case rightParenthesisType, leftParenthesisType:
+			return nil, ErrInvalidParenthesis
+		default:
+			return nil, ErrInvalidExpression
+		}
+	}
+	if len(stmts) == 0 {
+		return nil, ErrInvalidExpression
+	}
+	// chains like `a + b + c` are not nested in parser, but nested in tree
+	for _, stmt := range stmts {
+		if stmt.height() > p.maxDepth {
+			return nil, p.deep()
+		}
+	}
+	if len(stmts) == 1 {
+		return stmts[0], nil
+	}
+	return newNode(scriptNode, "", 0, stmts...), nil
+}
+
+func (p *parser) deep() error {
+	return fmt.Errorf("%w: nesting is deeper than %d", ErrLimit, p.maxDepth)
+}
+
+// parseStatement parses `let name = expr`, `name = expr` or just expression
+func (p *parser) parseStatement() (*node, error) {
+	isLet := p.peek(0).Type == variableType && p.peek(0).SValue == "let" && p.peek(1).Type == variableType && isAssign(p.peek(2))
+	start := p.peek(0).Pos
+	if isLet {
+		p.pos++
+	}
+	if p.peek(0).Type != variableType || !isAssign(p.peek(1)) {
+		return p.parseExpr()
+	}
+	name := p.next().SValue
+	p.pos++
+	value, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if isLet || p.lets[name] {
+		p.lets[name] = true
+		return p.span(newNode(letNode, name, 0, value), start), nil
+	}
+	return p.span(newNode(assignNode, name, 0, value), start), nil
+}
+
+// parseExpr parses expression with optional ternary condition `cond ? a : b`
+func (p *parser) parseExpr() (*node, error) {
+	cond, err := p.parseBinary(math.MinInt32)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek(0).Type != questionType {
+		return cond, nil
+	}
+	p.pos++
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > p.maxDepth {
+		return nil, p.deep()
+	}
+	a, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.next().Type != colonType {
+		return nil, ErrInvalidExpression
+	}
+	b, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return p.span(newNode(conditionNode, "", 0, cond, a, b), cond.Pos), nil
+}
+
+// parseBinary parses operators with priority at least minPriority
+func (p *parser) parseBinary(minPriority int) (*node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > p.maxDepth {
+		return nil, p.deep()
+	}
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek(0).Type == operatorType {
+		tkn := p.peek(0)
+		op, ok := p.operators[tkn.SValue]
+		if !ok {
+			return nil, &UnknownOperatorError{Op: tkn.SValue}
+		}
+		if op.Priority < minPriority {
+			break
+		}
+		p.pos++
+		next := op.Priority + 1
+		if op.Assoc == RightAssoc {
+			next = op.Priority
+		}
+		right, err := p.parseBinary(next)
+		if err != nil {
+			return nil, err
+		}
+		left = p.span(newNode(operatorNode, op.Op, 0, left, right), left.Pos)
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (*node, error) {
+	if tkn := p.peek(0); tkn.Type == operatorType && tkn.SValue == "-" {
+		p.pos++
+		operand, err := p.parseBinary(p.negPriority)
+		if err != nil {
+			return nil, err
+		}
+		return p.span(newNode(negNode, "", 0, operand), tkn.Pos), nil
+	}
+	return p.parsePostfix()
+}
+
+// parsePostfix parses primary expression with indexes and keys like `xs[0][1]` or `items[0].price`
+func (p *parser) parsePostfix() (*node, error) {
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek(0).Type {
+		case leftBracketType:
+			p.pos++
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if p.next().Type != rightBracketType {
+				return nil, ErrInvalidExpression
+			}
+			expr = p.span(newNode(indexNode, "", 0, expr, index), expr.Pos)
+		case dotType:
+			p.pos++
+			key := p.next()
+			if key.Type != variableType {
+				return nil, ErrInvalidExpression
+			}
+			expr = p.span(newNode(memberNode, key.SValue, 0, expr), expr.Pos)
+		default:
+			return expr, nil
+		}
+	}
+}
+
+// lambdaParams returns count of tokens of lambda head `x =>` or `(a, b) =>` at current position
+func (p *parser) lambdaParams() ([]string, int) {
+	if p.peek(0).Type == variableType && isArrow(p.peek(1)) {
+		return []string{p.peek(0).SValue}, 2
+	}
+	if p.peek(0).Type != leftParenthesisType {
+		return nil, 0
+	}
+	var params []string
+	i := 1
+	for ; p.peek(i).Type != rightParenthesisType; i++ {
+		if i%2 == 1 && p.peek(i).Type == variableType {
+			params = append(params, p.peek(i).SValue)
+		} else if i%2 == 1 || p.peek(i).Type != funcSep {
+			return nil, 0
+		}
+	}
+	if !isArrow(p.peek(i+1)) || p.peek(i-1).Type == funcSep {
+		return nil, 0
+	}
+	return params, i + 2
+}
+
+func (p *parser) parsePrimary() (*node, error) {
+	if params, skip := p.lambdaParams(); skip > 0 {
+		start := p.peek(0).Pos
+		p.pos += skip
+		body, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		lambda := newNode(lambdaNode, "", 0, body)
+		lambda.Params = params
+		return p.span(lambda, start), nil
+	}
+	tkn := p.next()
+	switch tkn.Type {
+	case literalType:
+		return p.span(newNode(literalNode, "", tkn.FValue), tkn.Pos), nil
+	case variableType:
+		return p.span(newNode(variableNode, tkn.SValue, 0), tkn.Pos), nil
+	case functionType:
+		p.pos++ // tokenizer always emits left parenthesis after function
+		fn := newNode(functionNode, tkn.SValue, 0)
+		if p.peek(0).Type == rightParenthesisType {
+			p.pos++
+			return p.span(fn, tkn.Pos), nil
+		}
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			fn.Args = append(fn.Args, arg)
+			switch p.next().Type {
+			case funcSep:
+				continue
+			case rightParenthesisType:
+				return p.span(fn, tkn.Pos), nil
+			case eof:
+				return nil, ErrInvalidParenthesis
+			default:
+				return nil, ErrInvalidExpression
+			}
+		}
+	case leftParenthesisType:
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().Type != rightParenthesisType {
+			return nil, ErrInvalidParenthesis
+		}
+		return p.span(expr, tkn.Pos), nil
+	case leftBracketType:
+		list := newNode(listNode, "", 0)
+		if p.peek(0).Type == rightBracketType {
+			p.pos++
+			return p.span(list, tkn.Pos), nil
+		}
+		for {
+			item, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			list.Args = append(list.Args, item)
+			switch p.next().Type {
+			case funcSep:
+				continue
+			case rightBracketType:
+				return p.span(list, tkn.Pos), nil
+			default:
+				return nil, ErrInvalidExpression
+			}
+		}
+	case rightParenthesisType:
+		return nil, ErrInvalidParenthesis
+	}
+	return nil, ErrInvalidExpression
+}
+
+func isAssign(tkn *token) bool {
+	return tkn.Type == operatorType && tkn.SValue == "="
+}
+
+func isArrow(tkn *token) bool {
+	return tkn.Type == operatorType && tkn.SValue == "=>"
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import "testing"
+
+func TestOperatorSemantics(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{"10 - 2 - 3", 5},
+		{"8 / 2 / 2", 2},
+		{"10 - 2 + 3", 11},
+		{"2 - 3 * 4 - 1", -11},
+		{"2 ^ 3 ^ 2", 512},
+		{"-x", -3},
+		{"-x ^ 2", -9},
+		{"2 * -x", -6},
+		{"-(x + 1)", -4},
+		{"x - -3", 6},
+		{"-3 ^ 2", 9}, // negative literal is a number, not unary minus
+	}
+	for _, test := range tests {
+		if err := c.Prepare(test.expression); err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		actual, err := c.Execute(map[string]float64{"x": 3})
+		if err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+		}
+		if actual != test.expected {
+			t.Errorf("%s: expected %f, actual %f", test.expression, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"math"
+)
+
+// NumericPolicy defines handling of division by zero and non-finite results
+// of operators and numeric functions
+type NumericPolicy int
+
+// IEEEPolicy returns results as is, so `1/0` is +Inf
+// DivisionByZeroPolicy returns ErrDivisionByZero when right operand of `/` or `%` is zero
+// NonFinitePolicy returns ErrNonFinite when operator or function returns NaN or Inf
+// SubstitutePolicy replaces NaN or Inf result of operator or function by substitute value
+const (
+	IEEEPolicy NumericPolicy = iota
+	DivisionByZeroPolicy
+	NonFinitePolicy
+	SubstitutePolicy
+)
+
+// numeric applies policy to result of operation
+type numeric struct {
+	policy     NumericPolicy
+	substitute float64
+}
+
+func (nm numeric) check(res float64, err error) (float64, error) {
+	if err != nil || !math.IsNaN(res) && !math.IsInf(res, 0) {
+		return res, err
+	}
+	switch nm.policy {
+	case NonFinitePolicy:
+		return 0, ErrNonFinite
+	case SubstitutePolicy:
+		return nm.substitute, nil
+	}
+	return res, nil
+}
+
+// operator returns copy of operator with policy applied to Fn
+func (nm numeric) operator(op *Operator) *Operator {
+	if nm.policy == IEEEPolicy || op.Fn == nil {
+		return op
+	}
+	res := *op
+	fn := op.Fn
+	if nm.policy == DivisionByZeroPolicy {
+		if op.Op == "/" || op.Op == "%" {
+			res.Fn = func(a, b float64) (float64, error) {
+				if b == 0 {
+					return 0, ErrDivisionByZero
+				}
+				return fn(a, b)
+			}
+		}
+		return &res
+	}
+	res.Fn = func(a, b float64) (float64, error) {
+		return nm.check(fn(a, b))
+	}
+	return &res
+}
+
+// function returns copy of Go function with policy applied to Fn and CtxFn
+func (nm numeric) function(f *Function) *Function {
+	if nm.policy == IEEEPolicy || nm.policy == DivisionByZeroPolicy || f.Fn == nil || f.body != nil {
+		return f
+	}
+	res := *f
+	fn, ctxFn := f.Fn, f.CtxFn
+	res.Fn = func(args ...float64) (float64, error) {
+		return nm.check(fn(args...))
+	}
+	if ctxFn != nil {
+		res.CtxFn = func(ctx context.Context, args ...float64) (float64, error) {
+			return nm.check(ctxFn(ctx, args...))
+		}
+	}
+	return &res
+}
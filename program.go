@@ -0,0 +1,192 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// program is prepared expression with layout of its free variables.
+// It holds its own copy of operators and functions, so it is immutable after
+// preparation and safe for concurrent execution.
+type program struct {
+	root           *node
+	layout         map[string]int
+	names          []string
+	assigned       map[string]bool // names assigned anywhere in script, bound inside lambdas
+	shadowed       map[string]bool // names bound anywhere in script, calls to them may be lambdas
+	compiled       *compiled
+	functions      map[string]*Function
+	operators      map[string]*Operator
+	recursionLimit int
+	stepLimit      int
+	callLimit      int
+	stats          Stats
+}
+
+func newProgram(root *node, c *Calc) *program {
+	p := &program{
+		root:           root,
+		layout:         map[string]int{},
+		functions:      make(map[string]*Function, len(c.functions)),
+		operators:      make(map[string]*Operator, len(c.operators)),
+		recursionLimit: c.recursionLimit,
+		stepLimit:      c.stepLimit,
+		callLimit:      c.callLimit,
+	}
+	for name, fn := range c.functions {
+		p.functions[name] = c.numeric.function(fn.memoized())
+	}
+	for name, op := range c.operators {
+		p.operators[name] = c.numeric.operator(op)
+	}
+	p.shadowed = shadowingNames(root, map[string]bool{})
+	p.root = p.optimize(root, c.fastMath)
+	p.assigned = assignedNames(p.root, map[string]bool{})
+	p.assignSlots(p.root, map[string]bool{})
+	p.eliminate()
+	if p.stepLimit == 0 && p.callLimit == 0 {
+		p.compiled = p.compile()
+	}
+	return p
+}
+
+// assignSlots sets slot indexes of free variables, bound variables get slot -1.
+// Variables are bound by assignments, let-bindings, lambda parameters and binding functions.
+// Lambdas may be called after later assignments, so inside them every assigned name is bound.
+func (p *program) assignSlots(n *node, bound map[string]bool) {
+	switch n.Type {
+	case variableNode:
+		if bound[strings.Split(n.SValue, ".")[0]] {
+			n.Slot = -1
+			return
+		}
+		slot, ok := p.layout[n.SValue]
+		if !ok {
+			slot = len(p.names)
+			p.layout[n.SValue] = slot
+			p.names = append(p.names, n.SValue)
+		}
+		n.Slot = slot
+	case assignNode, letNode:
+		p.assignSlots(n.Args[0], bound)
+		bound[n.SValue] = true
+	case lambdaNode:
+		inner := make(map[string]bool, len(bound)+len(n.Params))
+		for name := range bound {
+			inner[name] = true
+		}
+		for name := range p.assigned {
+			inner[name] = true
+		}
+		for _, name := range n.Params {
+			inner[name] = true
+		}
+		p.assignSlots(n.Args[0], inner)
+	case functionNode:
+		fn, ok := lookupFunction(p.functions, n.SValue, len(n.Args))
+		name, named := boundName(n)
+		if !ok || !fn.Binds || !named {
+			for _, arg := range n.Args {
+				p.assignSlots(arg, bound)
+			}
+			return
+		}
+		inner := make(map[string]bool, len(bound)+1)
+		for name := range bound {
+			inner[name] = true
+		}
+		inner[name] = true
+		p.assignSlots(n.Args[0], inner)
+		n.Args[1].Slot = -1
+		for _, arg := range n.Args[2:] {
+			p.assignSlots(arg, bound)
+		}
+	default:
+		for _, arg := range n.Args {
+			p.assignSlots(arg, bound)
+		}
+	}
+}
+
+// assignedNames adds names of assignments and let-bindings under n to names
+func assignedNames(n *node, names map[string]bool) map[string]bool {
+	if n.Type == assignNode || n.Type == letNode {
+		names[n.SValue] = true
+	}
+	for _, arg := range n.Args {
+		assignedNames(arg, names)
+	}
+	return names
+}
+
+// execute runs program with variables from map
+func (p *program) execute(vars map[string]float64) (float64, error) {
+	if p.compiled != nil {
+		return p.compiled.executeMap(p.names, vars)
+	}
+	slots, err := p.slotsOf(vars)
+	if err != nil {
+		return 0, err
+	}
+	return p.executeSlots(slots)
+}
+
+// executeSlots runs program with variables placed at layout indexes
+func (p *program) executeSlots(slots []float64) (float64, error) {
+	if len(slots) != len(p.names) {
+		return 0, fmt.Errorf("expected %d slots, got %d", len(p.names), len(slots))
+	}
+	if p.compiled != nil {
+		return p.compiled.execute(slots)
+	}
+	res, err := p.evaluate(&env{}, slots)
+	if err != nil {
+		return 0, err
+	}
+	return resultNumber(res)
+}
+
+func (p *program) evaluate(e *env, slots []float64) (Value, error) {
+	return p.evaluateContext(nil, e, slots)
+}
+
+func (p *program) evaluateContext(ctx context.Context, e *env, slots []float64) (Value, error) {
+	e.locals = map[string]Value{}
+	e.assigned = map[string]Value{}
+	e.st = &state{ctx: ctx, slots: slots, cache: make([]Value, p.stats.Shared)}
+	return p.eval(p.root, e)
+}
+
+// slotsOf returns values of free variables from map in layout order
+func (p *program) slotsOf(vars map[string]float64) ([]float64, error) {
+	slots := make([]float64, len(p.names))
+	for i, name := range p.names {
+		v, ok := vars[name]
+		if !ok {
+			return nil, &UnknownVariableError{Name: name}
+		}
+		slots[i] = v
+	}
+	return slots, nil
+}
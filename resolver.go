@@ -0,0 +1,79 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Resolver resolves variables by name during execution.
+// It returns false if variable is unknown.
+type Resolver interface {
+	Resolve(name string) (Value, bool, error)
+}
+
+// ResolverFunc is adapter to use ordinary function as Resolver
+type ResolverFunc func(name string) (Value, bool, error)
+
+// Resolve calls f(name)
+func (f ResolverFunc) Resolve(name string) (Value, bool, error) {
+	return f(name)
+}
+
+// MapResolver resolves numeric variables from map
+type MapResolver map[string]float64
+
+// Resolve returns variable from map
+func (m MapResolver) Resolve(name string) (Value, bool, error) {
+	v, ok := m[name]
+	return Number(v), ok, nil
+}
+
+// ValuesResolver resolves variables of any type supported by ValueOf from map.
+// Dotted names like `payload.qty` are resolved by walking nested maps.
+type ValuesResolver map[string]interface{}
+
+// Resolve returns variable from map converted to Value
+func (m ValuesResolver) Resolve(name string) (Value, bool, error) {
+	v, ok := m[name]
+	if !ok {
+		path := strings.Split(name, ".")
+		if v, ok = m[path[0]]; !ok || len(path) == 1 {
+			return nil, false, nil
+		}
+		for i, key := range path[1:] {
+			switch nested := v.(type) {
+			case map[string]interface{}:
+				v, ok = nested[key]
+			case Map:
+				v, ok = nested[key]
+			default:
+				return nil, false, fmt.Errorf("expected map at '%s', got %T", strings.Join(path[:i+1], "."), v)
+			}
+			if !ok {
+				return nil, false, fmt.Errorf("missing key '%s' at '%s'", key, name)
+			}
+		}
+	}
+	res, err := ValueOf(v)
+	return res, true, err
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"fmt"
+	"math"
+)
+
+// SolveMethod is numeric method of root finding
+type SolveMethod int
+
+// BrentMethod finds root between Min and Max, values of expression at them must have different signs
+// NewtonMethod finds root starting from Guess using derivative computed by ExecuteGradient
+const (
+	BrentMethod SolveMethod = iota
+	NewtonMethod
+)
+
+// DefaultTolerance is default tolerance of Solve
+// DefaultMaxIterations is default limit of iterations of Solve
+const (
+	DefaultTolerance     = 1e-10
+	DefaultMaxIterations = 100
+)
+
+// epsilon is difference between 1 and next float64, relative precision of root
+const epsilon = 2.220446049250313e-16
+
+// SolveOptions configures Solve
+type SolveOptions struct {
+	Vars          map[string]float64 // values of other variables
+	Method        SolveMethod
+	Min, Max      float64 // bracket of root for BrentMethod
+	Guess         float64 // initial value for NewtonMethod
+	Tolerance     float64 // DefaultTolerance if zero
+	MaxIterations int     // DefaultMaxIterations if zero
+}
+
+// ConvergenceError is returned when numeric method doesn't converge
+type ConvergenceError struct {
+	Reason     string
+	Iterations int
+	X          float64 // last approximation of root or integral
+	Residual   float64 // value of expression at X or error estimate of integral
+}
+
+func (e *ConvergenceError) Error() string {
+	return fmt.Sprintf("not converged after %d iterations: %s (x = %g, residual = %g)", e.Iterations, e.Reason, e.X, e.Residual)
+}
+
+// Solve finds value of variable that makes expression zero.
+// Expression can be an equation `lhs = rhs`, then value makes both sides equal.
+// Prepared expression of calculator is not changed.
+func (c *Calc) Solve(expression, variable string, opts SolveOptions) (float64, error) {
+	t := c.newTokenizer(expression, c.limits)
+	if err := t.tokenize(); err != nil {
+		return 0, err
+	}
+	root, err := c.parseEquation(expression, t.tkns)
+	if err != nil {
+		return 0, err
+	}
+	if err := c.limits.check(root, c.functions); err != nil {
+		return 0, err
+	}
+	p := newProgram(root, c)
+	slot, ok := p.layout[variable]
+	if !ok {
+		return 0, fmt.Errorf("expression doesn't depend on variable '%s'", variable)
+	}
+	vars := make(map[string]float64, len(opts.Vars)+1)
+	for name, v := range opts.Vars {
+		vars[name] = v
+	}
+	vars[variable] = 0
+	slots, err := p.slotsOf(vars)
+	if err != nil {
+		return 0, err
+	}
+	s := &solver{p: p, slots: slots, slot: slot, variable: variable, tol: opts.Tolerance, maxIter: opts.MaxIterations}
+	if s.tol <= 0 {
+		s.tol = DefaultTolerance
+	}
+	if s.maxIter <= 0 {
+		s.maxIter = DefaultMaxIterations
+	}
+	if opts.Method == NewtonMethod {
+		return s.newton(opts.Guess)
+	}
+	return s.brent(opts.Min, opts.Max)
+}
+
+// parseEquation parses `lhs = rhs` as `(lhs) - (rhs)` or just expression
+func (c *Calc) parseEquation(expression string, tkns []*token) (*node, error) {
+	depth := 0
+	for i, tkn := range tkns {
+		switch {
+		case tkn.Type == leftParenthesisType || tkn.Type == leftBracketType:
+			depth++
+		case tkn.Type == rightParenthesisType || tkn.Type == rightBracketType:
+			depth--
+		case depth == 0 && isAssign(tkn):
+			lhs, err := c.newParser(expression, tkns[:i], c.limits).parseScript()
+			if err != nil {
+				return nil, err
+			}
+			rhs, err := c.newParser(expression, tkns[i+1:], c.limits).parseScript()
+			if err != nil {
+				return nil, err
+			}
+			return newNode(operatorNode, "-", 0, lhs, rhs), nil
+		}
+	}
+	return c.newParser(expression, tkns, c.limits).parseScript()
+}
+
+// solver finds root of program by one of its variables
+type solver struct {
+	p        *program
+	slots    []float64
+	slot     int
+	variable string
+	tol      float64
+	maxIter  int
+}
+
+func (s *solver) f(x float64) (float64, error) {
+	s.slots[s.slot] = x
+	return s.p.executeSlots(s.slots)
+}
+
+// brent implements Brent's method
+func (s *solver) brent(a, b float64) (float64, error) {
+	if !(a < b) {
+		return 0, fmt.Errorf("invalid bracket [%g, %g]", a, b)
+	}
+	fa, err := s.f(a)
+	if err != nil {
+		return 0, err
+	}
+	fb, err := s.f(b)
+	if err != nil {
+		return 0, err
+	}
+	if fa == 0 {
+		return a, nil
+	}
+	if fb == 0 {
+		return b, nil
+	}
+	if fa*fb > 0 {
+		return 0, fmt.Errorf("%w: values at %g and %g have same sign", ErrNoBracket, a, b)
+	}
+	c, fc := b, fb
+	var d, e float64
+	for i := 1; i <= s.maxIter; i++ {
+		if fb*fc > 0 {
+			c, fc = a, fa
+			d = b - a
+			e = d
+		}
+		if math.Abs(fc) < math.Abs(fb) {
+			a, b, c = b, c, b
+			fa, fb, fc = fb, fc, fb
+		}
+		tol := 2*epsilon*math.Abs(b) + 0.5*s.tol
+		m := 0.5 * (c - b)
+		if math.Abs(m) <= tol || fb == 0 {
+			return b, nil
+		}
+		if math.Abs(e) >= tol && math.Abs(fa) > math.Abs(fb) {
+			// inverse quadratic interpolation or secant step
+			var p, q float64
+			r := fb / fa
+			if a == c {
+				p = 2 * m * r
+				q = 1 - r
+			} else {
+				q = fa / fc
+				t := fb / fc
+				p = r * (2*m*q*(q-t) - (b-a)*(t-1))
+				q = (q - 1) * (t - 1) * (r - 1)
+			}
+			if p > 0 {
+				q = -q
+			} else {
+				p = -p
+			}
+			if 2*p < math.Min(3*m*q-math.Abs(tol*q), math.Abs(e*q)) {
+				e = d
+				d = p / q
+			} else {
+				d, e = m, m
+			}
+		} else {
+			d, e = m, m
+		}
+		a, fa = b, fb
+		if math.Abs(d) > tol {
+			b += d
+		} else {
+			b += math.Copysign(tol, m)
+		}
+		if fb, err = s.f(b); err != nil {
+			return 0, err
+		}
+		if math.IsNaN(fb) {
+			return 0, &ConvergenceError{Reason: "expression is NaN", Iterations: i, X: b, Residual: fb}
+		}
+	}
+	return 0, &ConvergenceError{Reason: "iterations limit exceeded", Iterations: s.maxIter, X: b, Residual: fb}
+}
+
+// newton implements Newton's method
+func (s *solver) newton(x float64) (float64, error) {
+	wrt := []string{s.variable}
+	fx := math.NaN()
+	for i := 1; i <= s.maxIter; i++ {
+		s.slots[s.slot] = x
+		var grad []float64
+		var err error
+		if fx, grad, err = s.p.executeGradient(s.slots, wrt); err != nil {
+			return 0, err
+		}
+		if math.Abs(fx) <= s.tol {
+			return x, nil
+		}
+		if grad[0] == 0 || math.IsNaN(grad[0]) || math.IsInf(grad[0], 0) {
+			return 0, &ConvergenceError{Reason: fmt.Sprintf("derivative is %g", grad[0]), Iterations: i, X: x, Residual: fx}
+		}
+		step := fx / grad[0]
+		x -= step
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return 0, &ConvergenceError{Reason: "diverged", Iterations: i, X: x, Residual: fx}
+		}
+		if math.Abs(step) <= s.tol*(1+math.Abs(x)) {
+			return x, nil
+		}
+	}
+	return 0, &ConvergenceError{Reason: "iterations limit exceeded", Iterations: s.maxIter, X: x, Residual: fx}
+}
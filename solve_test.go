@@ -0,0 +1,87 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestSolve(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddFunctions(MathFunctions)
+	margin := "(price * (1 - discount) - cost) / (price * (1 - discount)) = 0.2"
+	vars := map[string]float64{"price": 100, "cost": 60}
+	tests := []struct {
+		name       string
+		expression string
+		variable   string
+		opts       SolveOptions
+		want       float64
+	}{
+		{"brent", "x^2 - 2", "x", SolveOptions{Min: 0, Max: 2}, math.Sqrt2},
+		{"newton", "x^2 - 2", "x", SolveOptions{Method: NewtonMethod, Guess: 1}, math.Sqrt2},
+		{"large root", "x^2 - 20000000000000000", "x", SolveOptions{Min: 0, Max: 1e9}, 1.414213562373095e8},
+		{"equation", "cos(x) = x", "x", SolveOptions{Min: 0, Max: 1}, 0.7390851332151607},
+		{"goal seek brent", margin, "discount", SolveOptions{Vars: vars, Max: 0.5}, 0.25},
+		{"goal seek newton", margin, "discount", SolveOptions{Vars: vars, Method: NewtonMethod}, 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Solve(tt.expression, tt.variable, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tt.want) > 1e-9*math.Max(1, math.Abs(tt.want)) {
+				t.Errorf("Solve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSolveErrors(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	if _, err := c.Solve("x^2 + 1", "x", SolveOptions{Min: -1, Max: 1}); !errors.Is(err, ErrNoBracket) {
+		t.Errorf("expected ErrNoBracket, got %v", err)
+	}
+	var convergence *ConvergenceError
+	_, err := c.Solve("x^2 + 1", "x", SolveOptions{Method: NewtonMethod, Guess: 0})
+	if !errors.As(err, &convergence) || convergence.Iterations != 1 {
+		t.Errorf("expected ConvergenceError at zero derivative, got %v", err)
+	}
+	_, err = c.Solve("x^2 + 1", "x", SolveOptions{Method: NewtonMethod, Guess: 3, MaxIterations: 10})
+	if !errors.As(err, &convergence) || convergence.Iterations != 10 {
+		t.Errorf("expected ConvergenceError after 10 iterations, got %v", err)
+	}
+	if _, err := c.Solve("x + y", "x", SolveOptions{Min: -1, Max: 1}); err == nil {
+		t.Error("expected error of unknown variable y")
+	}
+	if _, err := c.Solve("y + 1", "x", SolveOptions{Min: -1, Max: 1}); err == nil {
+		t.Error("expected error of missing variable x")
+	}
+	c.SetLimits(Limits{DeniedOperators: []string{"^"}})
+	if _, err := c.Solve("x^2 - 2", "x", SolveOptions{Min: 0, Max: 2}); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("expected ErrNotAllowed, got %v", err)
+	}
+}
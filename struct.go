@@ -0,0 +1,154 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// structFields caches field indexes of struct types
+var structFields sync.Map // map[reflect.Type]map[string][]int
+
+// structResolver resolves variables from exported fields of struct
+type structResolver struct {
+	v reflect.Value
+}
+
+// NewStructResolver returns Resolver of exported fields of struct (or pointer to struct).
+// Field is resolved by `expr:"name"` tag, its name or its name in lower case.
+// Fields of nested structs are resolved by dotted paths like `customer.tier`.
+func NewStructResolver(v interface{}) Resolver {
+	return &structResolver{v: reflect.ValueOf(v)}
+}
+
+// Resolve returns field by dotted path
+func (r *structResolver) Resolve(name string) (Value, bool, error) {
+	v := r.v
+	path := strings.Split(name, ".")
+	for i, part := range path {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil, false, fmt.Errorf("nil value at '%s'", strings.Join(path[:i], "."))
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			if i == 0 {
+				return nil, false, fmt.Errorf("expected struct, got %s", v.Kind())
+			}
+			return nil, false, nil
+		}
+		index, ok := fieldsOf(v.Type())[part]
+		if !ok {
+			return nil, false, nil
+		}
+		v = v.FieldByIndex(index)
+	}
+	res, err := reflectValue(v)
+	return res, true, err
+}
+
+func fieldsOf(t reflect.Type) map[string][]int {
+	if fields, ok := structFields.Load(t); ok {
+		return fields.(map[string][]int)
+	}
+	fields := map[string][]int{}
+	collectFields(t, nil, fields)
+	structFields.Store(t, fields)
+	return fields
+}
+
+func collectFields(t reflect.Type, prefix []int, fields map[string][]int) {
+	var embedded []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		index := append(append([]int{}, prefix...), i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			f.Index = index
+			embedded = append(embedded, f)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("expr")
+		switch tag {
+		case "-":
+		case "":
+			fields[f.Name] = index
+			if _, exists := fields[strings.ToLower(f.Name)]; !exists {
+				fields[strings.ToLower(f.Name)] = index
+			}
+		default:
+			fields[tag] = index
+		}
+	}
+	// fields of embedded structs don't shadow own fields
+	for _, f := range embedded {
+		promoted := map[string][]int{}
+		collectFields(f.Type, f.Index, promoted)
+		for name, index := range promoted {
+			if _, exists := fields[name]; !exists {
+				fields[name] = index
+			}
+		}
+	}
+}
+
+func reflectValue(v reflect.Value) (Value, error) {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return Number(v.Float()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Number(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Number(v.Uint()), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return Number(1), nil
+		}
+		return Number(0), nil
+	case reflect.Slice, reflect.Array:
+		l := make(List, v.Len())
+		for i := range l {
+			item, err := reflectValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			l[i] = item
+		}
+		return l, nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, fmt.Errorf("nil value")
+		}
+		return reflectValue(v.Elem())
+	}
+	if v.CanInterface() {
+		if res, ok := v.Interface().(Value); ok {
+			return res, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported value type %s", v.Type())
+}
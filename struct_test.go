@@ -0,0 +1,95 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import "testing"
+
+type testCustomer struct {
+	Tier     int
+	Discount float64 `expr:"disc"`
+}
+
+type testAudit struct {
+	Revision int
+}
+
+type testOrder struct {
+	testAudit
+	Price    float64
+	Qty      uint
+	Taxable  bool
+	Weights  []float32
+	Customer *testCustomer
+	Secret   float64 `expr:"-"`
+	note     float64
+}
+
+func TestExecuteStruct(t *testing.T) {
+	c := NewCalc()
+	c.AddOperators(MathOperators)
+	c.AddFunctions(ListFunctions)
+	order := testOrder{
+		testAudit: testAudit{Revision: 3},
+		Price:     10,
+		Qty:       2,
+		Taxable:   true,
+		Weights:   []float32{1, 2},
+		Customer:  &testCustomer{Tier: 2, Discount: 0.5},
+	}
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{"Price * qty", 20},
+		{"price * qty * (1 - customer.disc) + taxable", 11},
+		{"customer.tier + Customer.Tier + revision", 7},
+		{"sum(weights)", 3},
+	}
+	for _, test := range tests {
+		if err := c.Prepare(test.expression); err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		actual, err := c.ExecuteStruct(&order)
+		if err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+		}
+		if actual != test.expected {
+			t.Errorf("%s: expected %f, actual %f", test.expression, test.expected, actual)
+		}
+	}
+	for _, expression := range []string{"secret", "note", "customer", "customer.name", "price.value"} {
+		if err := c.Prepare(expression); err != nil {
+			t.Errorf("%s: %v", expression, err)
+			continue
+		}
+		if _, err := c.ExecuteStruct(order); err == nil {
+			t.Errorf("Expected error for %s", expression)
+		}
+	}
+	order.Customer = nil
+	if err := c.Prepare("customer.tier"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecuteStruct(order); err == nil {
+		t.Error("Expected error for nil customer")
+	}
+}
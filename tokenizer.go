@@ -22,6 +22,7 @@ package executor
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 )
 
@@ -30,86 +31,184 @@ type tokenizer struct {
 	numberBuffer  string
 	strBuffer     string
 	allowNegative bool
+	spaced        bool
 	tkns          []*token
 	operators     map[string]*Operator
+	limits        Limits
+	pos           int // offset of current character
+	numberPos     int // offset of number buffer
+	strPos        int // offset of string buffer
 }
 
 func newTokenizer(str string, operators map[string]*Operator) *tokenizer {
 	return &tokenizer{str: str, numberBuffer: "", strBuffer: "", allowNegative: true, tkns: []*token{}, operators: operators}
 }
 
+func (t *tokenizer) push(ttype tokenType, SValue string, FValue float64, pos int) {
+	tkn := newToken(ttype, SValue, FValue)
+	tkn.Pos, tkn.End = pos, pos+1
+	if len(SValue) > 1 {
+		tkn.End = pos + len(SValue)
+	}
+	t.tkns = append(t.tkns, tkn)
+}
+
+func (t *tokenizer) appendNumber(s string) {
+	if t.numberBuffer == "" {
+		t.numberPos = t.pos
+	}
+	t.numberBuffer += s
+}
+
+func (t *tokenizer) appendStr(s string) {
+	if t.strBuffer == "" {
+		t.strPos = t.pos
+	}
+	t.strBuffer += s
+}
+
 func (t *tokenizer) emptyNumberBufferAsLiteral() error {
 	if t.numberBuffer != "" {
 		f, err := strconv.ParseFloat(t.numberBuffer, 64)
 		if err != nil {
 			return fmt.Errorf("invalid number %s", t.numberBuffer)
 		}
-		t.tkns = append(t.tkns, newToken(literalType, "", f))
+		if t.limits.MaxLiteral > 0 && math.Abs(f) > t.limits.MaxLiteral {
+			return fmt.Errorf("%w: literal %s is greater than %g", ErrLimit, t.numberBuffer, t.limits.MaxLiteral)
+		}
+		t.push(literalType, "", f, t.numberPos)
+		t.tkns[len(t.tkns)-1].End = t.numberPos + len(t.numberBuffer)
 	}
 	t.numberBuffer = ""
 	return nil
 }
 
+// emptyNumberBufferAsFactor empties number buffer before variable, function or parenthesis.
+// Single minus becomes unary minus, number becomes multiplier.
+func (t *tokenizer) emptyNumberBufferAsFactor() error {
+	if t.numberBuffer == "-" {
+		t.push(operatorType, "-", 0, t.numberPos)
+		t.numberBuffer = ""
+		return nil
+	}
+	if t.numberBuffer != "" {
+		if err := t.emptyNumberBufferAsLiteral(); err != nil {
+			return err
+		}
+		t.push(operatorType, "*", 0, t.pos)
+	}
+	return nil
+}
+
+// emptyBuffers empties both buffers before separator
+func (t *tokenizer) emptyBuffers() error {
+	if err := t.emptyNumberBufferAsLiteral(); err != nil {
+		return err
+	}
+	t.emptyStrBufferAsVariable()
+	return nil
+}
+
 func (t *tokenizer) emptyStrBufferAsVariable() {
 	if t.strBuffer != "" {
-		t.tkns = append(t.tkns, newToken(variableType, t.strBuffer, 0))
+		t.push(variableType, t.strBuffer, 0, t.strPos)
 		t.strBuffer = ""
 	}
 }
 
 func (t *tokenizer) tokenize() error {
-	for _, ch := range t.str {
-		if ch == ' ' {
+	if t.limits.MaxLength > 0 && len(t.str) > t.limits.MaxLength {
+		return fmt.Errorf("%w: length %d is greater than %d", ErrLimit, len(t.str), t.limits.MaxLength)
+	}
+	for i, ch := range t.str {
+		t.pos = i
+		if t.limits.MaxTokens > 0 && len(t.tkns) > t.limits.MaxTokens {
+			return fmt.Errorf("%w: more than %d tokens", ErrLimit, t.limits.MaxTokens)
+		}
+		if isSpace(ch) {
+			t.spaced = t.strBuffer != ""
 			continue
 		}
 		ch := byte(ch)
+		if t.spaced && (isAlpha(ch) || isNumber(ch)) {
+			t.emptyStrBufferAsVariable()
+		}
+		t.spaced = false
 		switch true {
 		case isAlpha(ch):
-			if t.numberBuffer != "" {
-				if err := t.emptyNumberBufferAsLiteral(); err != nil {
-					return err
-				}
-				t.tkns = append(t.tkns, newToken(operatorType, "*", 0))
-				t.numberBuffer = ""
+			if err := t.emptyNumberBufferAsFactor(); err != nil {
+				return err
 			}
 			t.allowNegative = false
-			t.strBuffer += string(ch)
+			t.appendStr(string(ch))
+		case isNumber(ch) && t.strBuffer != "":
+			t.appendStr(string(ch))
 		case isNumber(ch):
-			t.numberBuffer += string(ch)
+			t.appendNumber(string(ch))
 			t.allowNegative = false
+		case isDot(ch) && t.strBuffer != "":
+			t.appendStr(string(ch))
+		case isDot(ch) && t.numberBuffer == "" && len(t.tkns) > 0 && t.tkns[len(t.tkns)-1].Type == rightBracketType:
+			t.push(dotType, "", 0, t.pos)
 		case isDot(ch):
-			t.numberBuffer += string(ch)
+			t.appendNumber(string(ch))
 			t.allowNegative = false
 		case isLP(ch):
 			if t.strBuffer != "" {
-				t.tkns = append(t.tkns, newToken(functionType, t.strBuffer, 0))
+				t.push(functionType, t.strBuffer, 0, t.strPos)
 				t.strBuffer = ""
-			} else if t.numberBuffer != "" {
-				if err := t.emptyNumberBufferAsLiteral(); err != nil {
-					return err
-				}
-				t.tkns = append(t.tkns, newToken(operatorType, "*", 0))
-				t.numberBuffer = ""
+			} else if err := t.emptyNumberBufferAsFactor(); err != nil {
+				return err
 			}
 			t.allowNegative = true
-			t.tkns = append(t.tkns, newToken(leftParenthesisType, "", 0))
+			t.push(leftParenthesisType, "", 0, t.pos)
 		case isRP(ch):
 			if err := t.emptyNumberBufferAsLiteral(); err != nil {
 				return err
 			}
 			t.emptyStrBufferAsVariable()
 			t.allowNegative = false
-			t.tkns = append(t.tkns, newToken(rightParenthesisType, "", 0))
+			t.push(rightParenthesisType, "", 0, t.pos)
+		case isLB(ch):
+			if err := t.emptyBuffers(); err != nil {
+				return err
+			}
+			t.allowNegative = true
+			t.push(leftBracketType, "", 0, t.pos)
+		case isRB(ch):
+			if err := t.emptyBuffers(); err != nil {
+				return err
+			}
+			t.allowNegative = false
+			t.push(rightBracketType, "", 0, t.pos)
 		case isComma(ch):
 			if err := t.emptyNumberBufferAsLiteral(); err != nil {
 				return err
 			}
 			t.emptyStrBufferAsVariable()
-			t.tkns = append(t.tkns, newToken(funcSep, "", 0))
+			t.push(funcSep, "", 0, t.pos)
+			t.allowNegative = true
+		case isSemicolon(ch):
+			if err := t.emptyBuffers(); err != nil {
+				return err
+			}
+			t.push(semicolonType, "", 0, t.pos)
+			t.allowNegative = true
+		case isQuestion(ch):
+			if err := t.emptyBuffers(); err != nil {
+				return err
+			}
+			t.push(questionType, "", 0, t.pos)
+			t.allowNegative = true
+		case isColon(ch):
+			if err := t.emptyBuffers(); err != nil {
+				return err
+			}
+			t.push(colonType, "", 0, t.pos)
 			t.allowNegative = true
 		default:
 			if t.allowNegative && ch == '-' {
-				t.numberBuffer += "-"
+				t.appendNumber("-")
 				t.allowNegative = false
 				continue
 			}
@@ -119,8 +218,9 @@ func (t *tokenizer) tokenize() error {
 			t.emptyStrBufferAsVariable()
 			if len(t.tkns) > 0 && t.tkns[len(t.tkns)-1].Type == operatorType {
 				t.tkns[len(t.tkns)-1].SValue += string(ch)
+				t.tkns[len(t.tkns)-1].End = t.pos + 1
 			} else {
-				t.tkns = append(t.tkns, newToken(operatorType, string(ch), 0))
+				t.push(operatorType, string(ch), 0, t.pos)
 			}
 			t.allowNegative = true
 		}
@@ -129,92 +229,10 @@ func (t *tokenizer) tokenize() error {
 		return err
 	}
 	t.emptyStrBufferAsVariable()
-	return nil
-}
-
-func (t *tokenizer) toRPN() ([]*token, error) {
-	var tkns []*token
-	var stack tokenStack
-	for _, tkn := range t.tkns {
-		switch tkn.Type {
-		case literalType:
-			tkns = append(tkns, tkn)
-		case variableType:
-			tkns = append(tkns, tkn)
-		case functionType:
-			stack.Push(tkn)
-		case funcSep:
-			for stack.Head().Type != leftParenthesisType {
-				if stack.Head().Type == eof {
-					return nil, ErrInvalidExpression
-				}
-				tkns = append(tkns, stack.Pop())
-			}
-		case operatorType:
-			leftOp, ok := t.operators[tkn.SValue]
-			if !ok {
-				return nil, fmt.Errorf("unknown operator: %s", tkn.SValue)
-			}
-			for {
-				if stack.Head().Type == operatorType {
-					rightOp, ok := t.operators[stack.Head().SValue]
-					if !ok {
-						return nil, fmt.Errorf("unknown operator: %s", stack.Head().SValue)
-					}
-					if leftOp.Priority < rightOp.Priority || (leftOp.Priority == rightOp.Priority && leftOp.Assoc == RightAssoc) {
-						tkns = append(tkns, stack.Pop())
-						continue
-					}
-				}
-				break
-			}
-			stack.Push(tkn)
-		case leftParenthesisType:
-			stack.Push(tkn)
-		case rightParenthesisType:
-			for stack.Head().Type != leftParenthesisType {
-				if stack.Head().Type == eof {
-					return nil, ErrInvalidParenthesis
-				}
-				tkns = append(tkns, stack.Pop())
-			}
-			stack.Pop()
-			if stack.Head().Type == functionType {
-				tkns = append(tkns, stack.Pop())
-			}
-		}
-	}
-	for stack.Head().Type != eof {
-		if stack.Head().Type == leftParenthesisType {
-			return nil, ErrInvalidParenthesis
-		}
-		tkns = append(tkns, stack.Pop())
+	if t.limits.MaxTokens > 0 && len(t.tkns) > t.limits.MaxTokens {
+		return fmt.Errorf("%w: more than %d tokens", ErrLimit, t.limits.MaxTokens)
 	}
-	return tkns, nil
-}
-
-type tokenStack struct {
-	ts []*token
-}
-
-func (ts *tokenStack) Push(t *token) {
-	ts.ts = append(ts.ts, t)
-}
-
-func (ts *tokenStack) Pop() *token {
-	if len(ts.ts) == 0 {
-		return &token{Type: eof}
-	}
-	var head *token
-	head, ts.ts = ts.ts[len(ts.ts)-1], ts.ts[:len(ts.ts)-1]
-	return head
-}
-
-func (ts *tokenStack) Head() *token {
-	if len(ts.ts) == 0 {
-		return &token{Type: eof}
-	}
-	return ts.ts[len(ts.ts)-1]
+	return nil
 }
 
 func isComma(ch byte) bool {
@@ -240,3 +258,27 @@ func isLP(ch byte) bool {
 func isRP(ch byte) bool {
 	return ch == ')'
 }
+
+func isLB(ch byte) bool {
+	return ch == '['
+}
+
+func isRB(ch byte) bool {
+	return ch == ']'
+}
+
+func isSemicolon(ch byte) bool {
+	return ch == ';'
+}
+
+func isQuestion(ch byte) bool {
+	return ch == '?'
+}
+
+func isColon(ch byte) bool {
+	return ch == ':'
+}
+
+func isSpace(ch rune) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
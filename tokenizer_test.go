@@ -31,33 +31,47 @@ func TestTokenize(t *testing.T) {
 		"*": {Op: "*", Assoc: LeftAssoc, Priority: 2, Fn: func(a float64, b float64) (float64, error) { return a * b, nil }},
 		"/": {Op: "/", Assoc: LeftAssoc, Priority: 2, Fn: func(a float64, b float64) (float64, error) { return a / b, nil }},
 	}
+	type expectedToken struct {
+		Type   tokenType
+		SValue string
+		FValue float64
+	}
 	tk := newTokenizer("((15/(7-(1+1)))*-3)-(-2+(1+1))", operators)
 	if err := tk.tokenize(); err != nil {
 		t.Error(err)
 	}
-	tkns, err := tk.toRPN()
-	if err != nil {
-		t.Error(err)
-	}
-	expected := []token{
+	expected := []expectedToken{
+		{leftParenthesisType, "", 0},
+		{leftParenthesisType, "", 0},
 		{literalType, "", 15},
+		{operatorType, "/", 0},
+		{leftParenthesisType, "", 0},
 		{literalType, "", 7},
-		{literalType, "", 1},
+		{operatorType, "-", 0},
+		{leftParenthesisType, "", 0},
 		{literalType, "", 1},
 		{operatorType, "+", 0},
-		{operatorType, "-", 0},
-		{operatorType, "/", 0},
-		{literalType, "", -3},
+		{literalType, "", 1},
+		{rightParenthesisType, "", 0},
+		{rightParenthesisType, "", 0},
+		{rightParenthesisType, "", 0},
 		{operatorType, "*", 0},
+		{literalType, "", -3},
+		{rightParenthesisType, "", 0},
+		{operatorType, "-", 0},
+		{leftParenthesisType, "", 0},
 		{literalType, "", -2},
-		{literalType, "", 1},
-		{literalType, "", 1},
 		{operatorType, "+", 0},
+		{leftParenthesisType, "", 0},
+		{literalType, "", 1},
 		{operatorType, "+", 0},
-		{operatorType, "-", 0},
+		{literalType, "", 1},
+		{rightParenthesisType, "", 0},
+		{rightParenthesisType, "", 0},
 	}
+	tkns := tk.tkns
 	if len(tkns) != len(expected) {
-		t.Errorf("Expected len = %d, got %d", len(expected), len(tkns))
+		t.Fatalf("Expected len = %d, got %d", len(expected), len(tkns))
 	}
 	for i, tkn := range tkns {
 		if tkn.Type != expected[i].Type {
@@ -70,11 +84,18 @@ func TestTokenize(t *testing.T) {
 			t.Errorf("Expected %f, got %f at pos %d", expected[i].FValue, tkn.FValue, i)
 		}
 	}
+	root, err := newParser(tkns, operators).parseScript()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := root.format(operators); s != "15 / (7 - (1 + 1)) * -3 - (-2 + (1 + 1))" {
+		t.Errorf("Expected parsed tree 15 / (7 - (1 + 1)) * -3 - (-2 + (1 + 1)), got %s", s)
+	}
 	tk = newTokenizer("a**b==10", operators)
 	if err := tk.tokenize(); err != nil {
 		t.Error(err)
 	}
-	expected = []token{
+	expected = []expectedToken{
 		{variableType, "a", 0},
 		{operatorType, "**", 0},
 		{variableType, "b", 0},
@@ -26,9 +26,27 @@ import (
 
 // ErrInvalidExpression invalid expression error
 // ErrInvalidParenthesis invalid parenthesis error
+// ErrInvalidDefinition invalid function definition error
+// ErrRecursionLimit recursion limit exceeded error
+// ErrStepLimit evaluation steps limit exceeded error
+// ErrCallLimit function calls limit exceeded error
+// ErrLimit expression exceeds limits error
+// ErrNotAllowed function or operator is not allowed error
+// ErrDivisionByZero division by zero error
+// ErrNonFinite NaN or Inf result error
+// ErrNoBracket root is not bracketed error
 var (
 	ErrInvalidExpression  = errors.New("invalid expression")
 	ErrInvalidParenthesis = errors.New("invalid parenthesis")
+	ErrInvalidDefinition  = errors.New("invalid function definition")
+	ErrRecursionLimit     = errors.New("recursion limit exceeded")
+	ErrStepLimit          = errors.New("steps limit exceeded")
+	ErrCallLimit          = errors.New("function calls limit exceeded")
+	ErrLimit              = errors.New("expression exceeds limits")
+	ErrNotAllowed         = errors.New("not allowed")
+	ErrDivisionByZero     = errors.New("division by zero")
+	ErrNonFinite          = errors.New("non-finite result")
+	ErrNoBracket          = errors.New("root is not bracketed")
 )
 
 type tokenType int
@@ -41,6 +59,12 @@ const (
 	rightParenthesisType
 	functionType
 	funcSep
+	semicolonType
+	questionType
+	colonType
+	leftBracketType
+	rightBracketType
+	dotType
 	eof
 )
 
@@ -48,6 +72,8 @@ type token struct {
 	Type   tokenType
 	SValue string
 	FValue float64
+	Pos    int // byte offset in expression
+	End    int // byte offset after token
 }
 
 func newToken(ttype tokenType, SValue string, FValue float64) *token {
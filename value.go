@@ -0,0 +1,219 @@
+// Copyright (c) 2020 Alexander Kiryukhin <a.kiryukhin@mail.ru>
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Value is result of evaluation: Number, List or *Lambda
+type Value interface {
+	String() string
+}
+
+// Number is scalar value
+type Number float64
+
+func (n Number) String() string {
+	return strconv.FormatFloat(float64(n), 'g', -1, 64)
+}
+
+// List is list of values, literal syntax is `[1, 2, 3]`
+type List []Value
+
+func (l List) String() string {
+	items := make([]string, len(l))
+	for i, v := range l {
+		items[i] = v.String()
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+// Map is map of values, usually decoded JSON object. Its keys are accessed like `payload.qty`.
+type Map map[string]Value
+
+func (m Map) String() string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		keys[i] = key + ": " + m[key].String()
+	}
+	return "{" + strings.Join(keys, ", ") + "}"
+}
+
+// Lambda is anonymous function like `x => x * 2` or `(acc, x) => acc + x`
+type Lambda struct {
+	params []string
+	body   *node
+	env    *env
+	prog   *program
+}
+
+func (l *Lambda) String() string {
+	n := &node{Type: lambdaNode, Params: l.params, Args: []*node{l.body}}
+	return n.format(l.prog.operators)
+}
+
+// Places returns count of lambda parameters
+func (l *Lambda) Places() int {
+	return len(l.params)
+}
+
+// Call calls lambda with arguments
+func (l *Lambda) Call(args ...Value) (Value, error) {
+	if len(args) != len(l.params) {
+		return nil, &ArityError{Want: len(l.params), Got: len(args)}
+	}
+	locals := make(map[string]Value, len(args))
+	for i, name := range l.params {
+		locals[name] = args[i]
+	}
+	st := l.env.st
+	if st.depth >= l.prog.recursionLimit {
+		return nil, ErrRecursionLimit
+	}
+	if err := l.prog.enter(st); err != nil {
+		return nil, err
+	}
+	st.depth++
+	defer func() { st.depth-- }()
+	return l.prog.eval(l.body, &env{locals: locals, parent: l.env, st: st})
+}
+
+// ValueOf converts float64, ints, bool, []float64, [][]float64, []interface{},
+// map[string]interface{}, map[string]float64 and Value to Value
+func ValueOf(v interface{}) (Value, error) {
+	switch v := v.(type) {
+	case Value:
+		return v, nil
+	case float64:
+		return Number(v), nil
+	case float32:
+		return Number(v), nil
+	case int:
+		return Number(v), nil
+	case int64:
+		return Number(v), nil
+	case int32:
+		return Number(v), nil
+	case bool:
+		if v {
+			return Number(1), nil
+		}
+		return Number(0), nil
+	case []float64:
+		l := make(List, len(v))
+		for i, f := range v {
+			l[i] = Number(f)
+		}
+		return l, nil
+	case [][]float64:
+		l := make(List, len(v))
+		for i, row := range v {
+			l[i], _ = ValueOf(row)
+		}
+		return l, nil
+	case map[string]interface{}:
+		m := make(Map, len(v))
+		for key, item := range v {
+			val, err := ValueOf(item)
+			if err != nil {
+				return nil, fmt.Errorf("key '%s': %w", key, err)
+			}
+			m[key] = val
+		}
+		return m, nil
+	case map[string]float64:
+		m := make(Map, len(v))
+		for key, item := range v {
+			m[key] = Number(item)
+		}
+		return m, nil
+	case []interface{}:
+		l := make(List, len(v))
+		for i, item := range v {
+			val, err := ValueOf(item)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = val
+		}
+		return l, nil
+	}
+	return nil, fmt.Errorf("unsupported value type %T", v)
+}
+
+func typeName(v Value) string {
+	switch v.(type) {
+	case Number:
+		return "number"
+	case List:
+		return "list"
+	case Map:
+		return "map"
+	case *Lambda:
+		return "lambda"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func asNumber(v Value) (float64, error) {
+	if n, ok := v.(Number); ok {
+		return float64(n), nil
+	}
+	return 0, fmt.Errorf("expected number, got %s", typeName(v))
+}
+
+func asList(v Value) (List, error) {
+	if l, ok := v.(List); ok {
+		return l, nil
+	}
+	return nil, fmt.Errorf("expected list, got %s", typeName(v))
+}
+
+func asLambda(v Value, places int) (*Lambda, error) {
+	l, ok := v.(*Lambda)
+	if !ok {
+		return nil, fmt.Errorf("expected lambda, got %s", typeName(v))
+	}
+	if l.Places() != places {
+		return nil, fmt.Errorf("expected lambda with %d args, got %d", places, l.Places())
+	}
+	return l, nil
+}
+
+func asIndex(v Value, length int) (int, error) {
+	f, err := asNumber(v)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) || f < 0 || int(f) >= length {
+		return 0, fmt.Errorf("index %s out of range [0, %d)", v, length)
+	}
+	return int(f), nil
+}